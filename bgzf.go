@@ -0,0 +1,238 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// bgzfEOF is the empty block that ends a BGZF file.
+var bgzfEOF = []byte{
+	0x1f, 0x8b, 0x08, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0x06, 0x00, 0x42, 0x43,
+	0x02, 0x00, 0x1b, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+const bgzfHeaderLen = 12 // up to and including XLEN
+
+// bgzfBlock is where a block starts, in the file and in the uncompressed
+// data.
+type bgzfBlock struct {
+	coff, uoff int64
+}
+
+// BGZF gives random access to the uncompressed contents of a BGZF file, as
+// written by bgzip and used for BAM and tabix-indexed files. A BGZF file is
+// a series of gzip members of up to 64 KiB each, called blocks, whose sizes
+// are recorded in their headers. The blocks before the one holding a read
+// are found by fetching just their headers and trailers, unless an index
+// from a .gzi file is loaded with LoadIndex. Only the blocks holding the
+// bytes read are fetched in full.
+//
+// A BGZF is not safe for concurrent use.
+type BGZF struct {
+	s *SeekingHTTP
+
+	// blocks are the starts of the blocks found so far, in order. Once
+	// complete, the last one is the end of the file.
+	blocks   []bgzfBlock
+	complete bool
+
+	cur     int // index in blocks of the block in curData, or -1
+	curData []byte
+}
+
+// Compile-time check of interface implementations.
+var _ io.ReaderAt = (*BGZF)(nil)
+
+// NewBGZF returns a BGZF reading the file s. It fetches the header of the
+// first block to check that s is a BGZF file.
+func NewBGZF(s *SeekingHTTP) (*BGZF, error) {
+	b := &BGZF{s: s, blocks: []bgzfBlock{{0, 0}}, cur: -1}
+	if err := b.extend(); err != nil {
+		return nil, err
+	}
+	if b.complete {
+		return nil, errors.New("bgzf: empty file")
+	}
+	return b, nil
+}
+
+// LoadIndex loads the block offsets from a .gzi index, as written by
+// bgzip -i, so that reads need not walk the blocks before them.
+func (b *BGZF) LoadIndex(gzi io.Reader) error {
+	var n uint64
+	if err := binary.Read(gzi, binary.LittleEndian, &n); err != nil {
+		return fmt.Errorf("bgzf: reading index: %w", err)
+	}
+	blocks := []bgzfBlock{{0, 0}}
+	for i := uint64(0); i < n; i++ {
+		var e [2]uint64
+		if err := binary.Read(gzi, binary.LittleEndian, &e); err != nil {
+			return fmt.Errorf("bgzf: reading index: %w", err)
+		}
+		blk := bgzfBlock{coff: int64(e[0]), uoff: int64(e[1])}
+		last := blocks[len(blocks)-1]
+		if blk.coff <= last.coff || blk.uoff < last.uoff {
+			return errors.New("bgzf: index is not in order")
+		}
+		blocks = append(blocks, blk)
+	}
+
+	b.blocks = blocks
+	b.complete = false
+	b.cur = -1
+	b.curData = nil
+	return nil
+}
+
+// extend finds the end of the last block found so far, by reading its
+// header and trailer, and adds it to b.blocks. At the end of the file, it
+// sets b.complete instead.
+func (b *BGZF) extend() error {
+	last := b.blocks[len(b.blocks)-1]
+
+	h := make([]byte, bgzfHeaderLen)
+	n, err := b.s.ReadAt(h, last.coff)
+	if n == 0 && err == io.EOF {
+		b.complete = true
+		return nil
+	}
+	if n < len(h) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if h[0] != 0x1f || h[1] != 0x8b || h[2] != 8 || h[3]&4 == 0 {
+		return fmt.Errorf("bgzf: no BGZF block header at %v", last.coff)
+	}
+
+	extra := make([]byte, binary.LittleEndian.Uint16(h[10:]))
+	if err := b.s.readFullAt(extra, last.coff+bgzfHeaderLen); err != nil {
+		return err
+	}
+	size := int64(-1)
+	for len(extra) >= 4 {
+		l := int(binary.LittleEndian.Uint16(extra[2:]))
+		if len(extra) < 4+l {
+			break
+		}
+		if extra[0] == 'B' && extra[1] == 'C' && l == 2 {
+			size = int64(binary.LittleEndian.Uint16(extra[4:])) + 1
+			break
+		}
+		extra = extra[4+l:]
+	}
+	if size < 0 {
+		return fmt.Errorf("bgzf: block at %v has no BC field", last.coff)
+	}
+
+	// The uncompressed size, ISIZE, is at the end of the block.
+	isize := make([]byte, 4)
+	if err := b.s.readFullAt(isize, last.coff+size-4); err != nil {
+		return err
+	}
+	b.blocks = append(b.blocks, bgzfBlock{
+		coff: last.coff + size,
+		uoff: last.uoff + int64(binary.LittleEndian.Uint32(isize)),
+	})
+	return nil
+}
+
+// find returns the index in b.blocks of the block holding the byte at
+// uncompressed offset off, or io.EOF if there is none.
+func (b *BGZF) find(off int64) (int, error) {
+	for {
+		// The last block starting at or before off. Empty blocks start
+		// where the next one does, so they are never chosen if the next
+		// one is known.
+		i := sort.Search(len(b.blocks), func(i int) bool { return b.blocks[i].uoff > off }) - 1
+		if i < len(b.blocks)-1 {
+			return i, nil
+		}
+		if b.complete {
+			return 0, io.EOF
+		}
+		if err := b.extend(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// block returns the uncompressed contents of block i, which must not be
+// the last in b.blocks.
+func (b *BGZF) block(i int) ([]byte, error) {
+	if i == b.cur {
+		return b.curData, nil
+	}
+
+	start, end := b.blocks[i], b.blocks[i+1]
+	raw := make([]byte, end.coff-start.coff)
+	if err := b.s.readFullAt(raw, start.coff); err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("bgzf: block at %v: %w", start.coff, err)
+	}
+	zr.Multistream(false)
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("bgzf: block at %v: %w", start.coff, err)
+	}
+	if int64(len(data)) != end.uoff-start.uoff {
+		return nil, fmt.Errorf("bgzf: block at %v has %v bytes, expected %v", start.coff, len(data), end.uoff-start.uoff)
+	}
+
+	b.cur, b.curData = i, data
+	return data, nil
+}
+
+// ReadAt reads len(buf) bytes of the uncompressed contents into buf,
+// starting at offset off.
+func (b *BGZF) ReadAt(buf []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	n := 0
+	for n < len(buf) {
+		pos := off + int64(n)
+		i, err := b.find(pos)
+		if err != nil {
+			return n, err
+		}
+		data, err := b.block(i)
+		if err != nil {
+			return n, err
+		}
+		n += copy(buf[n:], data[pos-b.blocks[i].uoff:])
+	}
+	return n, nil
+}
+
+// Size returns the size of the uncompressed contents. This means finding
+// all the blocks, which fetches the header and trailer of each one not
+// already known.
+func (b *BGZF) Size() (int64, error) {
+	for !b.complete {
+		if err := b.extend(); err != nil {
+			return 0, err
+		}
+	}
+	return b.blocks[len(b.blocks)-1].uoff, nil
+}
+
+// HasEOFMarker reports whether the file ends with the empty block that
+// bgzip writes at the end of a file. A file without it may have been
+// truncated.
+func (b *BGZF) HasEOFMarker() (bool, error) {
+	tail, _, err := b.s.ReadSuffix(int64(len(bgzfEOF)))
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(tail, bgzfEOF), nil
+}
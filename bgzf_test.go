@@ -0,0 +1,118 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// makeBGZF returns a BGZF file holding the given blocks, and the contents
+// of a .gzi index for it.
+func makeBGZF(t *testing.T, blocks [][]byte) ([]byte, []byte) {
+	var out bytes.Buffer
+	var index [][2]uint64
+	var uoff uint64
+	for i, data := range blocks {
+		if i > 0 {
+			index = append(index, [2]uint64{uint64(out.Len()), uoff})
+		}
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		// BSIZE is filled in once the size is known.
+		zw.Header.Extra = []byte{'B', 'C', 2, 0, 0, 0}
+		zw.Header.OS = 0xff
+		_, err := zw.Write(data)
+		assert.NoError(t, err)
+		assert.NoError(t, zw.Close())
+		block := b.Bytes()
+		binary.LittleEndian.PutUint16(block[16:], uint16(len(block)-1))
+		out.Write(block)
+		uoff += uint64(len(data))
+	}
+	out.Write(bgzfEOF)
+
+	var gzi bytes.Buffer
+	binary.Write(&gzi, binary.LittleEndian, uint64(len(index)))
+	binary.Write(&gzi, binary.LittleEndian, index)
+	return out.Bytes(), gzi.Bytes()
+}
+
+func TestBGZF(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var blocks [][]byte
+	var all []byte
+	for _, size := range []int{1000, 65280, 0, 3, 5000} {
+		data := make([]byte, size)
+		rnd.Read(data)
+		blocks = append(blocks, data)
+		all = append(all, data...)
+	}
+	file, gzi := makeBGZF(t, blocks)
+
+	for _, withIndex := range []bool{false, true} {
+		m := &RangeMockHTTPClient{data: file}
+		s := New("https://example.com/test.bam")
+		s.Client = m
+		s.Logger = &logger{t: t}
+		s.BlockSize = 512
+
+		b, err := NewBGZF(s)
+		if !assert.NoError(t, err) {
+			return
+		}
+		if withIndex {
+			assert.NoError(t, b.LoadIndex(bytes.NewReader(gzi)))
+		}
+
+		for _, r := range []struct{ off, n int64 }{
+			{66280, 3},  // the 3 byte block, after an empty one
+			{10, 20},    // within the first block
+			{990, 100},  // across blocks
+			{900, 6000}, // across several
+			{66282, 10}, // across an empty block
+			{int64(len(all)) - 5, 5},
+		} {
+			buf := make([]byte, r.n)
+			n, err := b.ReadAt(buf, r.off)
+			assert.NoError(t, err, "%+v", r)
+			assert.Equal(t, all[r.off:r.off+r.n], buf[:n], "%+v", r)
+		}
+
+		buf := make([]byte, 10)
+		n, err := b.ReadAt(buf, int64(len(all))-4)
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, all[len(all)-4:], buf[:n])
+
+		size, err := b.Size()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(all)), size)
+
+		ok, err := b.HasEOFMarker()
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+
+	// Without the EOF marker.
+	s := New("https://example.com/test.bam")
+	s.Client = &RangeMockHTTPClient{data: file[:len(file)-len(bgzfEOF)]}
+	b, err := NewBGZF(s)
+	assert.NoError(t, err)
+	ok, err := b.HasEOFMarker()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// Plain gzip is not BGZF.
+	var plain bytes.Buffer
+	zw := gzip.NewWriter(&plain)
+	zw.Write([]byte("hello"))
+	zw.Close()
+	s = New("https://example.com/test.gz")
+	s.Client = &RangeMockHTTPClient{data: plain.Bytes()}
+	_, err = NewBGZF(s)
+	assert.Error(t, err)
+}
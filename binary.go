@@ -0,0 +1,105 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrStringTooLong is returned when a string being read is longer than
+// the maximum allowed.
+var ErrStringTooLong = errors.New("string longer than maximum")
+
+// readFullAt is like ReadAt, but returns io.ErrUnexpectedEOF if the file
+// ends before buf is full.
+func (s *SeekingHTTP) readFullAt(buf []byte, off int64) error {
+	n, err := s.ReadAt(buf, off)
+	if n == len(buf) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReadCStringAt reads a NUL-terminated string starting at off, returning it
+// without the NUL. If there is no NUL in the max bytes after off, it returns
+// ErrStringTooLong. If the file ends first, it returns io.ErrUnexpectedEOF.
+func (s *SeekingHTTP) ReadCStringAt(off int64, max int) (string, error) {
+	if max < 0 {
+		return "", errors.New("negative maximum string length")
+	}
+
+	buf := make([]byte, max+1)
+	n, err := s.ReadAt(buf, off)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if i := bytes.IndexByte(buf[:n], 0); i >= 0 {
+		return string(buf[:i]), nil
+	}
+	if n < len(buf) {
+		return "", io.ErrUnexpectedEOF
+	}
+	return "", ErrStringTooLong
+}
+
+// ReadPascalStringAt reads a string starting at off that is preceded by its
+// length, stored as an unsigned integer of prefixLen bytes (1, 2 or 4) in
+// the given byte order. If the length is more than max, it returns
+// ErrStringTooLong. If the file ends first, it returns io.ErrUnexpectedEOF.
+func (s *SeekingHTTP) ReadPascalStringAt(off int64, prefixLen int, order binary.ByteOrder, max int) (string, error) {
+	if prefixLen != 1 && prefixLen != 2 && prefixLen != 4 {
+		return "", fmt.Errorf("unsupported length prefix size %v", prefixLen)
+	}
+	prefix := make([]byte, prefixLen)
+	if err := s.readFullAt(prefix, off); err != nil {
+		return "", err
+	}
+
+	var l uint64
+	switch prefixLen {
+	case 1:
+		l = uint64(prefix[0])
+	case 2:
+		l = uint64(order.Uint16(prefix))
+	case 4:
+		l = uint64(order.Uint32(prefix))
+	}
+
+	if max < 0 || l > uint64(max) {
+		return "", ErrStringTooLong
+	}
+	buf := make([]byte, l)
+	if err := s.readFullAt(buf, off+int64(prefixLen)); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// ReadBitsAt reads the bytes holding bitCount bits starting at bit
+// bitOffset of the file, where bit n is in byte n/8. It returns the bytes
+// and the offset of the first bit within the first byte, which is
+// bitOffset%8. Whether bits are numbered from the most or the least
+// significant end of a byte is up to the caller. If the file ends first, it
+// returns io.ErrUnexpectedEOF.
+func (s *SeekingHTTP) ReadBitsAt(bitOffset, bitCount int64) ([]byte, int, error) {
+	if bitOffset < 0 || bitCount < 0 {
+		return nil, 0, errors.New("negative bit offset or count")
+	}
+	shift := int(bitOffset % 8)
+	if bitCount == 0 {
+		return nil, shift, nil
+	}
+
+	first := bitOffset / 8
+	last := (bitOffset + bitCount - 1) / 8
+	buf := make([]byte, last-first+1)
+	if err := s.readFullAt(buf, first); err != nil {
+		return nil, 0, err
+	}
+	return buf, shift, nil
+}
@@ -0,0 +1,92 @@
+package seekinghttp
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadCStringAt(t *testing.T) {
+	s := New("https://example.com")
+	s.Client = &RangeMockHTTPClient{data: []byte("\x00\x00hello\x00world\x00unterminated")}
+	s.Logger = &logger{t: t}
+
+	str, err := s.ReadCStringAt(2, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", str)
+
+	str, err = s.ReadCStringAt(8, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "world", str)
+
+	str, err = s.ReadCStringAt(0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "", str)
+
+	_, err = s.ReadCStringAt(8, 4)
+	assert.ErrorIs(t, err, ErrStringTooLong)
+
+	_, err = s.ReadCStringAt(14, 100)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestReadPascalStringAt(t *testing.T) {
+	s := New("https://example.com")
+	s.Client = &RangeMockHTTPClient{data: []byte("\x05hello\x00\x05world\x06\x00\x00\x00foobar\x09short")}
+	s.Logger = &logger{t: t}
+
+	str, err := s.ReadPascalStringAt(0, 1, binary.LittleEndian, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", str)
+
+	str, err = s.ReadPascalStringAt(6, 2, binary.BigEndian, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, "world", str)
+
+	str, err = s.ReadPascalStringAt(13, 4, binary.LittleEndian, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, "foobar", str)
+
+	_, err = s.ReadPascalStringAt(13, 4, binary.LittleEndian, 5)
+	assert.ErrorIs(t, err, ErrStringTooLong)
+
+	_, err = s.ReadPascalStringAt(23, 1, binary.LittleEndian, 100)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, err = s.ReadPascalStringAt(0, 3, binary.LittleEndian, 100)
+	assert.Error(t, err)
+}
+
+func TestReadBitsAt(t *testing.T) {
+	s := New("https://example.com")
+	s.Client = &RangeMockHTTPClient{data: []byte{0x00, 0xf0, 0x0f, 0xaa, 0x55}}
+	s.Logger = &logger{t: t}
+
+	tests := []struct {
+		off, count int64
+		want       []byte
+		shift      int
+	}{
+		{0, 8, []byte{0x00}, 0},
+		{8, 1, []byte{0xf0}, 0},
+		{12, 8, []byte{0xf0, 0x0f}, 4},
+		{15, 2, []byte{0xf0, 0x0f}, 7},
+		{17, 15, []byte{0x0f, 0xaa}, 1},
+		{17, 16, []byte{0x0f, 0xaa, 0x55}, 1},
+		{39, 1, []byte{0x55}, 7},
+		{21, 0, nil, 5},
+	}
+	for _, tt := range tests {
+		got, shift, err := s.ReadBitsAt(tt.off, tt.count)
+		assert.NoError(t, err, "%v+%v", tt.off, tt.count)
+		assert.Equal(t, tt.want, got, "%v+%v", tt.off, tt.count)
+		assert.Equal(t, tt.shift, shift, "%v+%v", tt.off, tt.count)
+	}
+
+	_, _, err := s.ReadBitsAt(39, 2)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, _, err = s.ReadBitsAt(-1, 2)
+	assert.Error(t, err)
+}
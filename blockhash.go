@@ -0,0 +1,42 @@
+package seekinghttp
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math"
+)
+
+// BlockHash is the SHA-256 hash of one block of a file.
+type BlockHash [sha256.Size]byte
+
+// RemoteBlockHashes reads the file sequentially in blocks of blockSize
+// bytes and returns the hash of each block. The last block may be short.
+// Comparing the result with the hashes of another copy of the file, made
+// with RemoteBlockHashes or BlockHashes, shows which blocks differ.
+func (s *SeekingHTTP) RemoteBlockHashes(blockSize int) ([]BlockHash, error) {
+	return BlockHashes(io.NewSectionReader(s, 0, math.MaxInt64), blockSize)
+}
+
+// BlockHashes returns the hash of each block of blockSize bytes read from r,
+// for example a local file.
+func BlockHashes(r io.Reader, blockSize int) ([]BlockHash, error) {
+	if blockSize <= 0 {
+		return nil, errors.New("block size must be positive")
+	}
+
+	buf := make([]byte, blockSize)
+	var hashes []BlockHash
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			hashes = append(hashes, sha256.Sum256(buf[:n]))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return hashes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package seekinghttp
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteBlockHashes(t *testing.T) {
+	body := strings.Repeat("abcdefghij", 25) // 250 bytes
+	m := &RangeMockHTTPClient{data: []byte(body)}
+	s := New("https://example.com")
+	s.Client = m
+	s.Logger = &logger{t: t}
+	s.BlockSize = 100
+
+	hashes, err := s.RemoteBlockHashes(64)
+	assert.NoError(t, err)
+	assert.Equal(t, []BlockHash{
+		sha256.Sum256([]byte(body[0:64])),
+		sha256.Sum256([]byte(body[64:128])),
+		sha256.Sum256([]byte(body[128:192])),
+		sha256.Sum256([]byte(body[192:250])),
+	}, hashes)
+
+	// The same as hashing a local copy.
+	local, err := BlockHashes(strings.NewReader(body), 64)
+	assert.NoError(t, err)
+	assert.Equal(t, local, hashes)
+
+	// A copy with one changed byte differs in only that block.
+	changed := body[:130] + "X" + body[131:]
+	other, err := BlockHashes(strings.NewReader(changed), 64)
+	assert.NoError(t, err)
+	for i := range hashes {
+		assert.Equal(t, i == 2, hashes[i] != other[i], "block %d", i)
+	}
+}
+
+func TestRemoteBlockHashesEmpty(t *testing.T) {
+	s := New("https://example.com")
+	s.Client = &RangeMockHTTPClient{}
+	s.Logger = &logger{t: t}
+
+	hashes, err := s.RemoteBlockHashes(64)
+	assert.NoError(t, err)
+	assert.Empty(t, hashes)
+
+	_, err = s.RemoteBlockHashes(0)
+	assert.Error(t, err)
+}
@@ -1,12 +1,11 @@
 package main
 
 import (
-	"archive/tar"
 	"archive/zip"
 	"flag"
 	"fmt"
-	"io"
 	"log"
+	"net/http"
 	"strings"
 
 	"github.com/jeffallen/seekinghttp"
@@ -40,7 +39,28 @@ func (l CustomLogger) Fatal(args ...interface{}) {
 	log.Fatal(args...)
 }
 
+// listFlag collects the values of a flag that may be repeated.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
 var debug = flag.Bool("debug", false, "enable verbose output")
+var cacheDir = flag.String("cache", "", "keep fetched blocks in this `directory` for later runs")
+var slow = flag.Duration("slow", 0, "only log requests slower than this (unless -debug)")
+var headers listFlag
+var cookies listFlag
+
+func init() {
+	flag.Var(&headers, "H", "extra `header` to send, as \"Name: value\" (may be repeated)")
+	flag.Var(&cookies, "cookie", "`cookie` to send, as name=value (may be repeated)")
+}
 
 func main() {
 	flag.Parse()
@@ -58,23 +78,57 @@ func main() {
 
 	r := seekinghttp.New(flag.Arg(0))
 	r.SetLogger(logger)
+	r.SlowRequestThreshold = *slow
+	if *cacheDir != "" {
+		r.Fetcher = &seekinghttp.DiskCache{Dir: *cacheDir}
+	}
 
-	if strings.HasSuffix(flag.Arg(0), ".tar") {
-		t := tar.NewReader(r)
-		for {
-			h, err := t.Next()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				logger.Fatal(err)
-			}
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			logger.Fatal("Bad header, expected \"Name: value\": ", h)
+		}
+		if r.Header == nil {
+			r.Header = make(http.Header)
+		}
+		r.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	for _, c := range cookies {
+		name, value, ok := strings.Cut(c, "=")
+		if !ok {
+			logger.Fatal("Bad cookie, expected name=value: ", c)
+		}
+		r.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+
+	var format string
+	switch {
+	case strings.HasSuffix(flag.Arg(0), ".tar"):
+		format = seekinghttp.FormatTar
+	case strings.HasSuffix(flag.Arg(0), ".zip"):
+		format = seekinghttp.FormatZip
+	default:
+		// Look at the contents instead. The bytes read are cached, so
+		// this costs no extra request.
+		f, err := r.Sniff()
+		if err != nil {
+			logger.Fatal(err)
+		}
+		format = f
+	}
+
+	if format == seekinghttp.FormatTar {
+		entries, err := r.ListTarDetailed()
+		if err != nil {
+			logger.Fatal(err)
+		}
+		for _, h := range entries {
 			logger.Infof("File: %s", h.Name)
 		}
 		return
 	}
 
-	if strings.HasSuffix(flag.Arg(0), ".zip") {
+	if format == seekinghttp.FormatZip {
 		sz, err := r.Size()
 		if err != nil {
 			logger.Fatal(err)
@@ -91,5 +145,5 @@ func main() {
 		return
 	}
 
-	logger.Fatal("Unknown file type. URL does not end in .tar or .zip")
+	logger.Fatal("Unknown file type. URL does not end in .tar or .zip, and the contents are not a tar or zip file")
 }
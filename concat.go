@@ -0,0 +1,104 @@
+package seekinghttp
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sort"
+)
+
+// VirtualConcatReader presents several remote files, such as the volumes
+// of a split zip or tar archive, as one file. An offset in the whole is
+// translated into an offset in the right part, and reads that straddle
+// the boundary between parts are split between them.
+type VirtualConcatReader struct {
+	parts []*SeekingHTTP
+	// starts[i] is where parts[i] begins. The last entry is the total size.
+	starts []int64
+	offset int64
+}
+
+// Compile-time check of interface implementations.
+var _ io.ReadSeeker = (*VirtualConcatReader)(nil)
+var _ io.ReaderAt = (*VirtualConcatReader)(nil)
+
+// NewVirtualConcatReader returns a VirtualConcatReader for the parts, in
+// order. It needs the size of each part, so it calls Size on any part whose
+// size is not known yet.
+func NewVirtualConcatReader(parts ...*SeekingHTTP) (*VirtualConcatReader, error) {
+	v := &VirtualConcatReader{
+		parts:  parts,
+		starts: make([]int64, len(parts)+1),
+	}
+	for i, p := range parts {
+		size, err := p.Size()
+		if err != nil {
+			return nil, err
+		}
+		v.starts[i+1] = v.starts[i] + size
+	}
+	return v, nil
+}
+
+// Size returns the total size of the parts.
+func (v *VirtualConcatReader) Size() int64 {
+	return v.starts[len(v.parts)]
+}
+
+// ReadAt reads len(buf) bytes into buf starting at offset off in the whole.
+func (v *VirtualConcatReader) ReadAt(buf []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	n := 0
+	for n < len(buf) {
+		pos := off + int64(n)
+		if pos >= v.Size() {
+			return n, io.EOF
+		}
+
+		// Find the part that holds pos, skipping empty ones.
+		i := sort.Search(len(v.parts), func(i int) bool { return v.starts[i+1] > pos })
+		end := len(buf)
+		if rest := v.starts[i+1] - pos; rest < int64(end-n) {
+			end = n + int(rest)
+		}
+
+		m, err := v.parts[i].ReadAt(buf[n:end], pos-v.starts[i])
+		n += m
+		if err == io.EOF && n < end {
+			// The part is shorter than it said it was.
+			return n, io.ErrUnexpectedEOF
+		}
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Read reads from the current offset.
+func (v *VirtualConcatReader) Read(buf []byte) (int, error) {
+	n, err := v.ReadAt(buf, v.offset)
+	v.offset += int64(n)
+	return n, err
+}
+
+// Seek sets the offset for the next Read.
+func (v *VirtualConcatReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += v.offset
+	case io.SeekEnd:
+		offset += v.Size()
+	default:
+		return 0, os.ErrInvalid
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	v.offset = offset
+	return offset, nil
+}
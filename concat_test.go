@@ -0,0 +1,74 @@
+package seekinghttp
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newPart(t *testing.T, data string) (*SeekingHTTP, *RangeMockHTTPClient) {
+	m := &RangeMockHTTPClient{data: []byte(data)}
+	s := New("https://example.com/part")
+	s.Client = m
+	s.Logger = &logger{t: t}
+	return s, m
+}
+
+func TestVirtualConcatReader(t *testing.T) {
+	p1, m1 := newPart(t, "hello ")
+	empty, _ := newPart(t, "")
+	p2, m2 := newPart(t, "world")
+
+	v, err := NewVirtualConcatReader(p1, empty, p2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), v.Size())
+
+	// Straddle the boundary.
+	buf := make([]byte, 6)
+	n, err := v.ReadAt(buf, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "lo wor", string(buf[:n]))
+	assert.Equal(t, []string{"", "bytes=3-1048578"}, m1.ranges)
+	assert.Equal(t, []string{"", "bytes=0-1048575"}, m2.ranges)
+
+	n, err = v.ReadAt(buf, 8)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "rld", string(buf[:n]))
+
+	off, err := v.Seek(-5, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), off)
+	all, err := io.ReadAll(v)
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(all))
+}
+
+func TestVirtualConcatReaderSplitZip(t *testing.T) {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for _, name := range []string{"one.txt", "two.txt", "three.txt"} {
+		f, err := w.Create(name)
+		assert.NoError(t, err)
+		_, err = f.Write(bytes.Repeat([]byte(name), 100))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+	data := b.String()
+
+	half := len(data) / 2
+	p1, _ := newPart(t, data[:half])
+	p2, _ := newPart(t, data[half:])
+	v, err := NewVirtualConcatReader(p1, p2)
+	assert.NoError(t, err)
+
+	z, err := zip.NewReader(v, v.Size())
+	assert.NoError(t, err)
+	var names []string
+	for _, f := range z.File {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"one.txt", "two.txt", "three.txt"}, names)
+}
@@ -0,0 +1,93 @@
+package seekinghttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DiskCache is a BlockFetcher that keeps the blocks it fetches in files
+// under Dir, so that later readers of the same file, even in another run
+// of the program, can read them without going to the server.
+//
+// Blocks are stored under the URL and ETag of the file, and the block
+// size. The ETag is checked once per SeekingHTTP, with a HEAD request
+// unless an earlier response already showed it, so that blocks of a file
+// that has since changed are not used. Files without an ETag, or with
+// only a weak one, are not cached, since a weak ETag can stay the same
+// when the bytes change.
+type DiskCache struct {
+	Dir string
+}
+
+// Compile-time check of interface implementations.
+var _ BlockFetcher = (*DiskCache)(nil)
+
+// FetchBlock implements BlockFetcher.
+func (d *DiskCache) FetchBlock(s *SeekingHTTP, index int64, fetch func() ([]byte, error)) ([]byte, error) {
+	etag, err := s.ETag()
+	if err != nil {
+		return nil, err
+	}
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		return fetch()
+	}
+
+	path := d.path(s.URL, etag, s.blockSize(), index)
+	if data, err := os.ReadFile(path); err == nil {
+		if s.Logger != nil {
+			s.Logger.Debugf("disk cache hit: block %v in %v", index, path)
+		}
+		return data, nil
+	}
+
+	data, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	// If the file changed while fetching, the block belongs to another
+	// version of the file.
+	if now, _ := s.ETag(); now != etag {
+		return data, nil
+	}
+
+	// The cache is only an optimization, so failing to write to it is
+	// not an error.
+	if err := writeFileAtomic(path, data); err != nil && s.Logger != nil {
+		s.Logger.Debugf("disk cache: cannot store block %v: %v", index, err)
+	}
+	return data, nil
+}
+
+func (d *DiskCache) path(url, etag string, blockSize int, index int64) string {
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%d", url, etag, blockSize)))
+	return filepath.Join(d.Dir, hex.EncodeToString(key[:]), strconv.FormatInt(index, 10))
+}
+
+// writeFileAtomic writes the file via a temporary file, so that readers
+// never see a partially written block.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,119 @@
+package seekinghttp
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// etagServer serves data with an ETag, counting requests by method.
+type etagServer struct {
+	m     RangeMockHTTPClient
+	etag  string
+	count map[string]int
+}
+
+func (e *etagServer) Do(req *http.Request) (*http.Response, error) {
+	if e.count == nil {
+		e.count = make(map[string]int)
+	}
+	e.count[req.Method]++
+	resp, err := e.m.Do(req)
+	if err == nil {
+		resp.Header.Set("ETag", e.etag)
+	}
+	return resp, err
+}
+
+func TestDiskCache(t *testing.T) {
+	data := strings.Repeat("0123456789", 100)
+	srv := &etagServer{m: RangeMockHTTPClient{data: []byte(data)}, etag: `"v1"`}
+	cache := &DiskCache{Dir: t.TempDir()}
+
+	read := func(off int64, n int) string {
+		s := New("https://example.com/file")
+		s.Client = srv
+		s.Logger = &logger{t: t}
+		s.BlockSize = 100
+		s.Fetcher = cache
+
+		buf := make([]byte, n)
+		got, err := s.ReadAt(buf, off)
+		if err != io.EOF {
+			assert.NoError(t, err)
+		}
+		return string(buf[:got])
+	}
+
+	// The read straddles blocks 2 and 3.
+	assert.Equal(t, data[250:350], read(250, 100))
+	assert.Equal(t, 1, srv.count["HEAD"])
+	assert.Equal(t, 2, srv.count["GET"])
+
+	// A second reader gets the blocks from disk, after checking the ETag.
+	srv.count = nil
+	assert.Equal(t, data[210:390], read(210, 180))
+	assert.Equal(t, 1, srv.count["HEAD"])
+	assert.Equal(t, 0, srv.count["GET"])
+
+	// Reading at the end also needs the empty block after the end of
+	// the file, which the size from Content-Range says is not worth
+	// asking the server for.
+	srv.count = nil
+	assert.Equal(t, data[950:], read(950, 100))
+	assert.Equal(t, data[950:], read(950, 100))
+	assert.Equal(t, 2, srv.count["HEAD"])
+	assert.Equal(t, 1, srv.count["GET"])
+
+	// Once the file changes, the old blocks are not used.
+	srv.count = nil
+	srv.etag = `"v2"`
+	assert.Equal(t, data[250:350], read(250, 100))
+	assert.Equal(t, 1, srv.count["HEAD"])
+	assert.Equal(t, 2, srv.count["GET"])
+}
+
+func TestDiskCacheNoETag(t *testing.T) {
+	data := strings.Repeat("0123456789", 10)
+	m := &RangeMockHTTPClient{data: []byte(data)}
+	cache := &DiskCache{Dir: t.TempDir()}
+
+	for i := 0; i < 2; i++ {
+		s := New("https://example.com/file")
+		s.Client = m
+		s.Logger = &logger{t: t}
+		s.BlockSize = 50
+		s.Fetcher = cache
+
+		buf := make([]byte, 10)
+		_, err := s.ReadAt(buf, 20)
+		assert.NoError(t, err)
+		assert.Equal(t, data[20:30], string(buf))
+	}
+	// A HEAD and a GET each time, since nothing could be cached.
+	assert.Equal(t, []string{"", "bytes=0-49", "", "bytes=0-49"}, m.ranges)
+}
+
+func TestDiskCacheWeakETag(t *testing.T) {
+	data := strings.Repeat("0123456789", 10)
+	srv := &etagServer{m: RangeMockHTTPClient{data: []byte(data)}, etag: `W/"v1"`}
+	cache := &DiskCache{Dir: t.TempDir()}
+
+	for i := 0; i < 2; i++ {
+		s := New("https://example.com/file")
+		s.Client = srv
+		s.Logger = &logger{t: t}
+		s.BlockSize = 50
+		s.Fetcher = cache
+
+		buf := make([]byte, 10)
+		_, err := s.ReadAt(buf, 20)
+		assert.NoError(t, err)
+		assert.Equal(t, data[20:30], string(buf))
+	}
+	// Fetched each time, since a weak ETag does not identify the bytes.
+	assert.Equal(t, 2, srv.count["GET"])
+}
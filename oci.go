@@ -0,0 +1,131 @@
+package seekinghttp
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// layerPipelineDepth is how many requests are kept in flight while
+// streaming through an image layer.
+const layerPipelineDepth = 4
+
+// OCIBlobURL returns the URL of the blob with the given digest, such as an
+// image layer, in a repository of an OCI or Docker registry. The registry
+// is a host name, like "ghcr.io", or a URL if it is not served over HTTPS.
+func OCIBlobURL(registry, repository, digest string) (string, error) {
+	if !strings.Contains(registry, "://") {
+		registry = "https://" + registry
+	}
+	return JoinURLPath(registry, "v2", repository, "blobs", digest)
+}
+
+// NewOCIBlob returns a SeekingHTTP for a blob in an OCI or Docker registry,
+// as located by OCIBlobURL. If token is not empty, it is sent as a bearer
+// token, as most registries require even for public images.
+//
+// Registries usually answer with a redirect to a blob store. The default
+// Client follows it, keeping the Range header but dropping the token when
+// the blob store is on another domain, which is what the blob stores
+// expect.
+func NewOCIBlob(registry, repository, digest, token string) (*SeekingHTTP, error) {
+	u, err := OCIBlobURL(registry, repository, digest)
+	if err != nil {
+		return nil, err
+	}
+	s := New(u)
+	if token != "" {
+		s.Header = make(http.Header)
+		s.Header.Set("Authorization", "Bearer "+token)
+	}
+	return s, nil
+}
+
+// walkLayer calls fn with the header of each entry in an image layer, which
+// is a tar file, usually gzipped. A gzipped layer cannot be read at random,
+// so the whole layer up to the end of the walk is fetched, with several
+// requests in flight to hide their latency. fn may read the contents of the
+// entry from tr. Returning errStopWalk ends the walk without an error.
+func (s *SeekingHTTP) walkLayer(fn func(h *tar.Header, tr *tar.Reader) error) error {
+	p, err := s.NewPipeline(0, layerPipelineDepth)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	br := bufio.NewReader(p)
+	var r io.Reader = br
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(h, tr); err != nil {
+			if err == errStopWalk {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// ListLayer returns the headers of the files in an image layer. A gzipped
+// layer cannot be read at random, so all of it is fetched, with several
+// requests in flight to hide their latency.
+func (s *SeekingHTTP) ListLayer() ([]tar.Header, error) {
+	var headers []tar.Header
+	err := s.walkLayer(func(h *tar.Header, tr *tar.Reader) error {
+		headers = append(headers, *h)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// ExtractLayerFile copies the contents of the named file in an image layer
+// to w. The layer is only fetched up to the end of the file.
+func (s *SeekingHTTP) ExtractLayerFile(name string, w io.Writer) error {
+	found := false
+	err := s.walkLayer(func(h *tar.Header, tr *tar.Reader) error {
+		if h.Name != name {
+			return nil
+		}
+		found = true
+		if _, err := io.Copy(w, tr); err != nil {
+			return err
+		}
+		return errStopWalk
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("layer file %q: %w", name, os.ErrNotExist)
+	}
+	return nil
+}
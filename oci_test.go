@@ -0,0 +1,101 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOCIBlobURL(t *testing.T) {
+	u, err := OCIBlobURL("ghcr.io", "owner/image", "sha256:abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ghcr.io/v2/owner/image/blobs/sha256:abc", u)
+
+	u, err = OCIBlobURL("http://localhost:5000", "image", "sha256:abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:5000/v2/image/blobs/sha256:abc", u)
+}
+
+func TestLayer(t *testing.T) {
+	// Random contents, so that the layer does not compress to almost
+	// nothing.
+	rnd := rand.New(rand.NewSource(1))
+	big := make([]byte, 20000)
+	rnd.Read(big)
+	layer, _ := makeTar(t, []tarFile{
+		{"etc/os-release", "NAME=test\n"},
+		{"usr/bin/big", string(big)},
+		{"usr/share/doc/README", "read me\n"},
+	})
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(layer)
+	assert.NoError(t, zw.Close())
+
+	const digest = "sha256:0123"
+	var mu sync.Mutex
+	blob := gz.Bytes()
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/blobs/0123", r.URL.Path)
+		assert.Equal(t, "sig", r.URL.Query().Get("X-Signature"))
+		mu.Lock()
+		b := blob
+		mu.Unlock()
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b))
+	}))
+	defer store.Close()
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "/v2/library/test/blobs/"+digest, r.URL.Path)
+		http.Redirect(w, r, store.URL+"/blobs/0123?X-Signature=sig", http.StatusTemporaryRedirect)
+	}))
+	defer registry.Close()
+
+	s, err := NewOCIBlob(registry.URL, "library/test", digest, "token")
+	assert.NoError(t, err)
+	s.Logger = &logger{t: t}
+	s.BlockSize = 1000
+
+	headers, err := s.ListLayer()
+	assert.NoError(t, err)
+	var names []string
+	for _, h := range headers {
+		names = append(names, h.Name)
+	}
+	assert.Equal(t, []string{"etc/os-release", "usr/bin/big", "usr/share/doc/README"}, names)
+	assert.Equal(t, int64(len(big)), headers[1].Size)
+
+	var b bytes.Buffer
+	assert.NoError(t, s.ExtractLayerFile("usr/bin/big", &b))
+	assert.Equal(t, big, b.Bytes())
+	b.Reset()
+	assert.NoError(t, s.ExtractLayerFile("usr/share/doc/README", &b))
+	assert.Equal(t, "read me\n", b.String())
+	assert.ErrorIs(t, s.ExtractLayerFile("missing", &b), os.ErrNotExist)
+
+	// Uncompressed layers work too.
+	mu.Lock()
+	blob = layer
+	mu.Unlock()
+	b.Reset()
+	assert.NoError(t, s.ExtractLayerFile("etc/os-release", &b))
+	assert.Equal(t, "NAME=test\n", b.String())
+
+	// Without the token, the registry says no.
+	s, err = NewOCIBlob(registry.URL, "library/test", digest, "")
+	assert.NoError(t, err)
+	_, err = s.ListLayer()
+	assert.True(t, err != nil && strings.Contains(err.Error(), "401"), "%v", err)
+}
@@ -0,0 +1,64 @@
+package seekinghttp
+
+import (
+	"io"
+	"sort"
+)
+
+// readOverlaid is ReadAt with s.Overlay applied to what is read.
+func (s *SeekingHTTP) readOverlaid(buf []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, io.EOF
+	}
+	end := off + int64(len(buf))
+	offs := s.overlaps(off, end)
+
+	covered := off
+	for _, o := range offs {
+		if o > covered {
+			break
+		}
+		if e := o + int64(len(s.Overlay[o])); e > covered {
+			covered = e
+		}
+	}
+
+	n := len(buf)
+	var err error
+	if covered < end {
+		n, err = s.readAt(buf, off)
+	} else if s.Logger != nil {
+		s.Logger.Debugf("overlay hit: range (%v-%v) is within the overlay", off, end)
+	}
+
+	s.overlay(buf[:n], off, offs)
+	return n, err
+}
+
+// overlaps returns the offsets of the overlay entries that overlap the
+// range from off to end, in order.
+func (s *SeekingHTTP) overlaps(off, end int64) []int64 {
+	var offs []int64
+	for o, b := range s.Overlay {
+		if o < end && o+int64(len(b)) > off {
+			offs = append(offs, o)
+		}
+	}
+	sort.Slice(offs, func(i, j int) bool { return offs[i] < offs[j] })
+	return offs
+}
+
+// overlay copies the overlay entries at offs over buf, which holds the
+// bytes of the file from off.
+func (s *SeekingHTTP) overlay(buf []byte, off int64, offs []int64) {
+	for _, o := range offs {
+		b := s.Overlay[o]
+		if o < off {
+			b = b[off-o:]
+			o = off
+		}
+		if o-off < int64(len(buf)) {
+			copy(buf[o-off:], b)
+		}
+	}
+}
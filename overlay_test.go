@@ -0,0 +1,70 @@
+package seekinghttp
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverlay(t *testing.T) {
+	m := &RangeMockHTTPClient{data: []byte("0123456789abcdefghij")}
+	s := New("https://example.com")
+	s.Client = m
+	s.Logger = &logger{t: t}
+	s.BlockSize = 4
+	s.Overlay = map[int64][]byte{
+		2:  []byte("XY"),
+		3:  []byte("Z"),
+		10: []byte("ABCDE"),
+		18: []byte("!!"),
+	}
+
+	read := func(off int64, l int) string {
+		buf := make([]byte, l)
+		n, err := s.ReadAt(buf, off)
+		if err != io.EOF {
+			assert.NoError(t, err)
+		}
+		return string(buf[:n])
+	}
+
+	// Blended, with the later entry winning where they overlap.
+	assert.Equal(t, "01XZ4567", read(0, 8))
+	assert.Equal(t, []string{"bytes=0-7"}, m.ranges)
+
+	// Entirely within the overlay: no fetch.
+	assert.Equal(t, "BCD", read(11, 3))
+	assert.Equal(t, "XZ", read(2, 2))
+	assert.Equal(t, 1, len(m.ranges))
+
+	// Starting inside an entry, and running past the end of the file.
+	assert.Equal(t, "DEfgh", read(13, 5))
+	assert.Equal(t, "gh!!", read(16, 10))
+
+	// The cache holds what the server sent, not the overlay.
+	s.Overlay = nil
+	assert.Equal(t, "ghij", read(16, 4))
+}
+
+func TestOverlayPipeline(t *testing.T) {
+	m := &RangeMockHTTPClient{data: []byte("0123456789abcdefghij")}
+	s := New("https://example.com")
+	s.Client = m
+	s.Logger = &logger{t: t}
+	s.BlockSize = 4
+	s.Overlay = map[int64][]byte{
+		2:  []byte("XY"),
+		3:  []byte("Z"),
+		10: []byte("ABCDE"),
+		18: []byte("!!"),
+	}
+
+	// Across block boundaries, as for ReadAt.
+	p, err := s.NewPipeline(0, 2)
+	assert.NoError(t, err)
+	defer p.Close()
+	got, err := io.ReadAll(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "01XZ456789ABCDEfgh!!", string(got))
+}
@@ -0,0 +1,38 @@
+package seekinghttp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const parquetMagic = "PAR1"
+
+// ReadParquetFooter fetches the metadata footer of a Parquet file using two
+// small suffix range requests: one for the footer length and magic number
+// in the last 8 bytes, and one for exactly the footer. The returned bytes
+// are the Thrift-encoded FileMetaData.
+func (s *SeekingHTTP) ReadParquetFooter() ([]byte, error) {
+	tail, tailStart, err := s.ReadSuffix(8)
+	if err != nil {
+		return nil, err
+	}
+	if len(tail) < 8 || string(tail[4:]) != parquetMagic {
+		return nil, errors.New("parquet: not a Parquet file")
+	}
+
+	n := int64(binary.LittleEndian.Uint32(tail))
+	// Check the length against the file before fetching, rather than
+	// asking for up to 4 GiB on the word of a corrupt footer.
+	if n > tailStart-int64(len(parquetMagic)) {
+		return nil, errors.New("parquet: footer length is larger than the file")
+	}
+	buf, start, err := s.ReadSuffix(n + 8)
+	if err != nil {
+		return nil, err
+	}
+	// The footer must come after the leading magic number.
+	if int64(len(buf)) != n+8 || start < int64(len(parquetMagic)) {
+		return nil, errors.New("parquet: footer length is larger than the file")
+	}
+	return buf[:n], nil
+}
@@ -0,0 +1,53 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadParquetFooter(t *testing.T) {
+	footer := []byte("pretend this is thrift-encoded FileMetaData")
+
+	var b bytes.Buffer
+	b.WriteString("PAR1")
+	b.WriteString(strings.Repeat("column data ", 10000))
+	b.Write(footer)
+	binary.Write(&b, binary.LittleEndian, uint32(len(footer)))
+	b.WriteString("PAR1")
+
+	s := New("https://example.com/data.parquet")
+	m := &RangeMockHTTPClient{data: b.Bytes()}
+	s.Client = m
+	s.Logger = &logger{t: t}
+
+	got, err := s.ReadParquetFooter()
+	assert.NoError(t, err)
+	assert.Equal(t, footer, got)
+	assert.Equal(t, []string{"bytes=-8", "bytes=-51"}, m.ranges)
+}
+
+func TestReadParquetFooterBadFile(t *testing.T) {
+	s := New("https://example.com/data.parquet")
+	s.Client = &RangeMockHTTPClient{data: []byte("PAR1 not really parquet")}
+	s.Logger = &logger{t: t}
+
+	_, err := s.ReadParquetFooter()
+	assert.Error(t, err)
+
+	// A footer length pointing before the start of the file.
+	var b bytes.Buffer
+	b.WriteString("PAR1")
+	binary.Write(&b, binary.LittleEndian, uint32(1000))
+	b.WriteString("PAR1")
+	m := &RangeMockHTTPClient{data: b.Bytes()}
+	s = New("https://example.com/data.parquet")
+	s.Client = m
+	_, err = s.ReadParquetFooter()
+	assert.Error(t, err)
+	// It is caught without asking for the footer.
+	assert.Equal(t, []string{"bytes=-8"}, m.ranges)
+}
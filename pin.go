@@ -0,0 +1,96 @@
+package seekinghttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// A PinnedDialer makes every connection to an address go to the same
+// server as the first one did. When a host name resolves to several
+// servers, as with DNS load balancing, this keeps all the range requests
+// of a session on one server, so they do not mix data from servers that
+// are not quite in sync. Use its DialContext method as the DialContext of
+// an http.Transport. TLS still checks the certificate against the host
+// name, since only the TCP connection is affected.
+//
+// The cost is that if the pinned server goes away, requests fail rather
+// than go to another one, and that the load is not spread over the
+// servers. A load balancer behind a single address is not affected; it
+// needs some other kind of stickiness, such as a cookie.
+type PinnedDialer struct {
+	// Dial makes the connections. If nil, a net.Dialer is used.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	mu     sync.Mutex
+	pinned map[string]string      // address asked for to address connected to
+	first  map[string]*sync.Mutex // held while making the first connection
+}
+
+// DialContext connects to the server that addr was pinned to, or if there
+// is none yet, to addr, pinning it to the server it connected to. Dials
+// made while the first one to addr is in progress wait for it, so that
+// they go to the server it pins rather than wherever addr resolves to.
+func (d *PinnedDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dial := d.Dial
+	if dial == nil {
+		var nd net.Dialer
+		dial = nd.DialContext
+	}
+
+	if pinned := d.Pinned(addr); pinned != "" {
+		return dial(ctx, network, pinned)
+	}
+
+	first := d.firstLock(addr)
+	first.Lock()
+	defer first.Unlock()
+	if pinned := d.Pinned(addr); pinned != "" {
+		return dial(ctx, network, pinned)
+	}
+	conn, err := dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pinned == nil {
+		d.pinned = make(map[string]string)
+	}
+	d.pinned[addr] = conn.RemoteAddr().String()
+	return conn, nil
+}
+
+// firstLock returns the lock held while making the first connection to
+// addr.
+func (d *PinnedDialer) firstLock(addr string) *sync.Mutex {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.first == nil {
+		d.first = make(map[string]*sync.Mutex)
+	}
+	l, ok := d.first[addr]
+	if !ok {
+		l = new(sync.Mutex)
+		d.first[addr] = l
+	}
+	return l
+}
+
+// Pinned returns the address that addr is pinned to, or "" if it is not
+// pinned yet.
+func (d *PinnedDialer) Pinned(addr string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.pinned[addr]
+}
+
+// pinnedClient returns a client like http.DefaultClient, but with its
+// connections made by d.
+func pinnedClient(d *PinnedDialer) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = d.DialContext
+	return &http.Client{Transport: t}
+}
@@ -0,0 +1,140 @@
+package seekinghttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinnedDialer(t *testing.T) {
+	// Two backends behind one name, with different versions of the file.
+	var backends []string
+	for _, v := range []string{"a", "b"} {
+		content := strings.Repeat(v, 1000)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.ServeContent(w, r, "file", time.Time{}, strings.NewReader(content))
+		}))
+		defer srv.Close()
+		backends = append(backends, srv.Listener.Addr().String())
+	}
+
+	var mu sync.Mutex
+	var dialed []string
+	next := 0
+	d := &PinnedDialer{
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			mu.Lock()
+			dialed = append(dialed, addr)
+			if addr == "lb.test:80" {
+				// Round robin DNS.
+				addr = backends[next%len(backends)]
+				next++
+			}
+			mu.Unlock()
+			var nd net.Dialer
+			return nd.DialContext(ctx, network, addr)
+		},
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.DialContext = d.DialContext
+	// A new connection for each request.
+	tr.DisableKeepAlives = true
+
+	s := New("http://lb.test/file")
+	s.Client = &http.Client{Transport: tr}
+	s.Logger = &logger{t: t}
+	s.BlockSize = 10
+
+	buf := make([]byte, 10)
+	var got []string
+	for off := int64(0); off < 50; off += 10 {
+		_, err := s.ReadAt(buf, off)
+		assert.NoError(t, err)
+		got = append(got, string(buf))
+	}
+	for _, g := range got {
+		assert.Equal(t, got[0], g)
+	}
+	assert.Equal(t, backends[0], d.Pinned("lb.test:80"))
+	assert.Equal(t, []string{"lb.test:80", backends[0], backends[0], backends[0], backends[0]}, dialed)
+}
+
+func TestPinAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.PinAddress = true
+	buf := make([]byte, 5)
+	_, err := s.ReadAt(buf, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "56789", string(buf))
+	assert.NotSame(t, http.DefaultClient, s.Client)
+	// The Client made for PinAddress is not mistaken for one set by the
+	// user.
+	assert.NoError(t, s.Validate())
+
+	s = New(srv.URL)
+	s.PinAddress = true
+	s.Client = http.DefaultClient
+	_, err = s.ReadAt(buf, 5)
+	assert.Error(t, err)
+}
+
+func TestPinnedDialerConcurrent(t *testing.T) {
+	var backends []string
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer l.Close()
+		backends = append(backends, l.Addr().String())
+	}
+
+	var mu sync.Mutex
+	next := 0
+	d := &PinnedDialer{
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if addr == "lb.test:80" {
+				mu.Lock()
+				addr = backends[next%len(backends)]
+				next++
+				mu.Unlock()
+				// Give the other first dials time to start.
+				time.Sleep(10 * time.Millisecond)
+			}
+			var nd net.Dialer
+			return nd.DialContext(ctx, network, addr)
+		},
+	}
+
+	// First dials at the same time, as from a Pipeline, all go to the
+	// same server.
+	var wg sync.WaitGroup
+	remotes := make([]string, 8)
+	for i := range remotes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := d.DialContext(context.Background(), "tcp", "lb.test:80")
+			if assert.NoError(t, err) {
+				remotes[i] = conn.RemoteAddr().String()
+				conn.Close()
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, r := range remotes {
+		assert.Equal(t, d.Pinned("lb.test:80"), r)
+	}
+}
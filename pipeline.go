@@ -0,0 +1,126 @@
+package seekinghttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Pipeline reads a file sequentially while keeping several range requests
+// in flight ahead of the reader, to hide the latency of each request on
+// slow links. Blocks are requested in ascending order and delivered to Read
+// in that same order. At most depth blocks of BlockSize bytes are in flight
+// or waiting to be read at any time. The blocks are always fetched from
+// the server, but the Overlay is applied to them as it is for ReadAt.
+type Pipeline struct {
+	s      *SeekingHTTP
+	size   int
+	cancel context.CancelFunc
+	queue  chan chan pipelineBlock
+	eof    chan struct{}
+	once   sync.Once
+
+	cur []byte
+	err error
+}
+
+type pipelineBlock struct {
+	data []byte
+	err  error
+}
+
+// Compile-time check of interface implementations.
+var _ io.ReadCloser = (*Pipeline)(nil)
+
+// NewPipeline starts reading s from offset off with up to depth requests
+// in flight. The Pipeline must be closed when it is no longer needed.
+func (s *SeekingHTTP) NewPipeline(off int64, depth int) (*Pipeline, error) {
+	if depth < 1 {
+		return nil, errors.New("pipeline depth must be at least 1")
+	}
+	if off < 0 {
+		return nil, errors.New("negative offset")
+	}
+
+	// Do the lazy initialization now, before there are goroutines.
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	if _, err := s.newReq(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pipeline{
+		s:      s,
+		size:   s.fetchSize(s.blockSize()),
+		cancel: cancel,
+		// The block the reader is waiting on is not in the queue.
+		queue: make(chan chan pipelineBlock, depth-1),
+		eof:   make(chan struct{}),
+	}
+	go p.run(ctx, off)
+	return p, nil
+}
+
+// run starts the fetch for each block in turn, blocking while the queue
+// is full.
+func (p *Pipeline) run(ctx context.Context, off int64) {
+	defer close(p.queue)
+	for {
+		ch := make(chan pipelineBlock, 1)
+		select {
+		case p.queue <- ch:
+		case <-p.eof:
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		go func(off int64) {
+			data, err := p.s.fetch(ctx, off, p.size)
+			if len(p.s.Overlay) > 0 {
+				p.s.overlay(data, off, p.s.overlaps(off, off+int64(len(data))))
+			}
+			if err == nil && len(data) < p.size {
+				// No point asking for blocks past the end.
+				p.once.Do(func() { close(p.eof) })
+			}
+			ch <- pipelineBlock{data: data, err: err}
+		}(off)
+		off += int64(p.size)
+	}
+}
+
+// Read reads the next bytes of the file.
+func (p *Pipeline) Read(buf []byte) (int, error) {
+	for len(p.cur) == 0 {
+		if p.err != nil {
+			return 0, p.err
+		}
+		ch, ok := <-p.queue
+		if !ok {
+			p.err = errors.New("pipeline closed")
+			continue
+		}
+		b := <-ch
+		switch {
+		case b.err != nil:
+			p.err = b.err
+		case len(b.data) < p.size:
+			p.err = io.EOF
+		}
+		p.cur = b.data
+	}
+
+	n := copy(buf, p.cur)
+	p.cur = p.cur[n:]
+	return n, nil
+}
+
+// Close stops the pipeline, cancelling any requests in flight.
+func (p *Pipeline) Close() error {
+	p.cancel()
+	return nil
+}
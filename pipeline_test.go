@@ -0,0 +1,74 @@
+package seekinghttp
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeline(t *testing.T) {
+	var data strings.Builder
+	for i := 0; data.Len() < 1050; i++ {
+		data.WriteString(strconv.Itoa(i))
+	}
+	m := &RangeMockHTTPClient{data: []byte(data.String())}
+
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	s := New("https://example.com")
+	s.Logger = &logger{t: t}
+	s.BlockSize = 100
+	s.Client = clientFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		// Earlier blocks take longer, so they finish out of order.
+		from, _, _ := strings.Cut(strings.TrimPrefix(req.Header.Get("Range"), "bytes="), "-")
+		off, _ := strconv.Atoi(from)
+		time.Sleep(time.Duration(2000-off) * 10 * time.Microsecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		inFlight--
+		return m.Do(req)
+	})
+
+	p, err := s.NewPipeline(0, 4)
+	assert.NoError(t, err)
+	defer p.Close()
+
+	got, err := io.ReadAll(p)
+	assert.NoError(t, err)
+	assert.Equal(t, data.String(), string(got))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Greater(t, maxInFlight, 1)
+	assert.LessOrEqual(t, maxInFlight, 4)
+}
+
+func TestPipelineClose(t *testing.T) {
+	s := New("https://example.com")
+	s.Logger = &logger{t: t}
+	s.BlockSize = 10
+	s.Client = clientFunc(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+
+	p, err := s.NewPipeline(0, 2)
+	assert.NoError(t, err)
+	p.Close()
+	_, err = p.Read(make([]byte, 10))
+	assert.Error(t, err)
+}
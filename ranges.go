@@ -0,0 +1,99 @@
+package seekinghttp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// contentRange is a parsed Content-Range header.
+type contentRange struct {
+	// first and last are the offsets of the first and last bytes sent,
+	// or -1 for the "bytes */size" form sent with a 416 response.
+	first, last int64
+
+	// size is the size of the whole file, or -1 if the server does not
+	// know it.
+	size int64
+}
+
+// parseContentRange parses the value of a Content-Range header, which is
+// one of "bytes first-last/size", "bytes first-last/*" or "bytes */size".
+// It is strict, rejecting anything else, including ranges that are
+// backwards or run past the end of the file.
+func parseContentRange(v string) (contentRange, error) {
+	bad := func() (contentRange, error) {
+		return contentRange{}, fmt.Errorf("bad Content-Range %q", v)
+	}
+
+	unit, rest, ok := strings.Cut(v, " ")
+	if !ok || !strings.EqualFold(unit, "bytes") {
+		return bad()
+	}
+	rng, size, ok := strings.Cut(rest, "/")
+	if !ok {
+		return bad()
+	}
+
+	cr := contentRange{first: -1, last: -1, size: -1}
+	if size != "*" {
+		n, err := parseDigits(size)
+		if err != nil {
+			return bad()
+		}
+		cr.size = n
+	}
+
+	if rng == "*" {
+		if cr.size < 0 {
+			return bad()
+		}
+		return cr, nil
+	}
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return bad()
+	}
+	var err1, err2 error
+	cr.first, err1 = parseDigits(first)
+	cr.last, err2 = parseDigits(last)
+	if err1 != nil || err2 != nil || cr.first > cr.last {
+		return bad()
+	}
+	if cr.size >= 0 && cr.last >= cr.size {
+		return bad()
+	}
+	return cr, nil
+}
+
+// parseRange parses a Range header value asking for a single range of
+// bytes, "bytes=first-last", as made by fmtRange, returning the offset
+// and length of the range.
+func parseRange(v string) (from, l int64, err error) {
+	rng := strings.TrimPrefix(v, "bytes=")
+	first, last, ok := strings.Cut(rng, "-")
+	if len(rng) == len(v) || !ok {
+		return 0, 0, fmt.Errorf("bad Range %q", v)
+	}
+	from, err1 := parseDigits(first)
+	to, err2 := parseDigits(last)
+	if err1 != nil || err2 != nil || from > to {
+		return 0, 0, fmt.Errorf("bad Range %q", v)
+	}
+	return from, to - from + 1, nil
+}
+
+// parseDigits parses a non-negative decimal number, without the sign or
+// spaces that strconv.ParseInt allows.
+func parseDigits(s string) (int64, error) {
+	if s == "" {
+		return 0, errors.New("empty number")
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, fmt.Errorf("bad digit in %q", s)
+		}
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
@@ -0,0 +1,122 @@
+package seekinghttp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		in   string
+		want contentRange
+		ok   bool
+	}{
+		{"bytes 0-99/1000", contentRange{0, 99, 1000}, true},
+		{"bytes 990-999/1000", contentRange{990, 999, 1000}, true},
+		{"Bytes 5-5/*", contentRange{5, 5, -1}, true},
+		{"bytes */1000", contentRange{-1, -1, 1000}, true},
+		{"bytes 0-0/1", contentRange{0, 0, 1}, true},
+		{"", contentRange{}, false},
+		{"bytes", contentRange{}, false},
+		{"items 0-9/10", contentRange{}, false},
+		{"bytes=0-99/1000", contentRange{}, false},
+		{"bytes 0-99", contentRange{}, false},
+		{"bytes 0-99/", contentRange{}, false},
+		{"bytes */*", contentRange{}, false},
+		{"bytes 99-0/1000", contentRange{}, false},
+		{"bytes 0-1000/1000", contentRange{}, false},
+		{"bytes -1-99/1000", contentRange{}, false},
+		{"bytes +0-99/1000", contentRange{}, false},
+		{"bytes 0 -99/1000", contentRange{}, false},
+		{"bytes 0-99/1000 ", contentRange{}, false},
+		{"bytes 0-99/99999999999999999999", contentRange{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseContentRange(tt.in)
+		if !tt.ok {
+			assert.Error(t, err, tt.in)
+			continue
+		}
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	from, l, err := parseRange("bytes=10-19")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), from)
+	assert.Equal(t, int64(10), l)
+
+	for _, in := range []string{"", "10-19", "bytes=-10", "bytes=10-", "bytes=19-10", "bytes=1-2,5-6", "bytes= 1-2"} {
+		_, _, err := parseRange(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func FuzzParseContentRange(f *testing.F) {
+	f.Add("bytes 0-99/1000")
+	f.Add("bytes 5-5/*")
+	f.Add("bytes */1000")
+	f.Add("bytes 99-0/1000")
+	f.Fuzz(func(t *testing.T, in string) {
+		cr, err := parseContentRange(in)
+		if err != nil {
+			return
+		}
+
+		// Whatever is accepted makes sense, and survives formatting and
+		// parsing again.
+		size := "*"
+		if cr.size >= 0 {
+			size = fmt.Sprint(cr.size)
+		}
+		var out string
+		if cr.first < 0 {
+			if cr.last != -1 || cr.size < 0 {
+				t.Fatalf("%q: bad unsatisfied range %+v", in, cr)
+			}
+			out = "bytes */" + size
+		} else {
+			if cr.first > cr.last || (cr.size >= 0 && cr.last >= cr.size) {
+				t.Fatalf("%q: bad range %+v", in, cr)
+			}
+			out = fmt.Sprintf("bytes %d-%d/%s", cr.first, cr.last, size)
+		}
+		again, err := parseContentRange(out)
+		if err != nil || again != cr {
+			t.Fatalf("%q: %+v formatted as %q parsed as %+v, %v", in, cr, out, again, err)
+		}
+	})
+}
+
+func FuzzRangeRoundTrip(f *testing.F) {
+	f.Add(int64(0), int64(1))
+	f.Add(int64(100), int64(4096))
+	f.Fuzz(func(t *testing.T, from, l int64) {
+		if from < 0 || l < 1 || from > (1<<63-1)-l {
+			return
+		}
+		rng := fmtRange(from, l)
+		gotFrom, gotL, err := parseRange(rng)
+		if err != nil || gotFrom != from || gotL != l {
+			t.Fatalf("fmtRange(%v, %v) = %q parsed as %v, %v, %v", from, l, rng, gotFrom, gotL, err)
+		}
+	})
+}
+
+func FuzzParseRange(f *testing.F) {
+	f.Add("bytes=0-99")
+	f.Add("bytes=-10")
+	f.Fuzz(func(t *testing.T, in string) {
+		from, l, err := parseRange(in)
+		if err != nil {
+			return
+		}
+		if from < 0 || l < 1 {
+			t.Fatalf("%q: bad range %v, %v", in, from, l)
+		}
+	})
+}
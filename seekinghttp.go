@@ -2,14 +2,37 @@ package seekinghttp
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
+// ErrSlowFirstByte is returned when the server does not start
+// responding within FirstByteTimeout.
+var ErrSlowFirstByte = errors.New("server did not respond within FirstByteTimeout")
+
+// ErrContentChanged is returned when the file on the server has changed
+// since it was first read.
+var ErrContentChanged = errors.New("content changed on the server")
+
+// ErrTooManyRequests is returned when an operation would need more than
+// MaxRequestsPerOp requests.
+var ErrTooManyRequests = errors.New("too many requests for one operation")
+
+// ErrResponseTooLarge is returned when reading a response would mean
+// reading more than MaxResponseBytes of its body.
+var ErrResponseTooLarge = errors.New("response body larger than MaxResponseBytes")
+
 type HttpClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
@@ -19,6 +42,16 @@ type Logger interface {
 	Debugf(format string, args ...interface{})
 }
 
+// A BlockFetcher supplies blocks of a file, for example from a cache.
+// When SeekingHTTP.Fetcher is set, reads that are not in the in-memory
+// cache are done a block at a time, where block i is the BlockSize bytes
+// starting at offset i*BlockSize. Only the last block of a file may be
+// shorter. FetchBlock returns the block with the given index, calling
+// fetch to get it from the server if need be.
+type BlockFetcher interface {
+	FetchBlock(s *SeekingHTTP, index int64, fetch func() ([]byte, error)) ([]byte, error)
+}
+
 // SeekingHTTP uses a series of HTTP GETs with Range headers
 // to implement io.ReadSeeker and io.ReaderAt.
 type SeekingHTTP struct {
@@ -28,12 +61,218 @@ type SeekingHTTP struct {
 	offset     int64
 	last       *bytes.Buffer
 	lastOffset int64
+	lastEOF    bool // last holds the end of the file
 	Logger     Logger
+
+	// BlockSize is the minimum number of bytes to fetch in each request.
+	// Bytes beyond those asked for are cached to serve later reads.
+	// If zero, DefaultBlockSize is used.
+	BlockSize int
+
+	// RangeTooLargeStatus lists response status codes that mean a range
+	// was too big for the server, or for a proxy in front of it. When a
+	// read gets one, it tries again asking for half as many bytes, but not
+	// fewer than were asked for by the caller, and remembers the smaller
+	// size for later requests, splitting bigger ones into pieces of that
+	// size. If nil, 413, 414 and 431 are used. Some proxies reply to large
+	// ranges with 400, which can be added here.
+	RangeTooLargeStatus []int
+
+	// KeepBehindBytes is how many bytes before the start of a new fetch to
+	// keep from the old contents of the cache, if they are there. This
+	// saves fetching again when a parser steps back a little, for example
+	// to re-read a length prefix, after reading past the end of the cache.
+	KeepBehindBytes int
+
+	// SniffLength is how many bytes Sniff reads from the start of the file.
+	// If zero, DefaultSniffLength is used.
+	SniffLength int
+
+	// StreamThreshold, if non-zero, makes reads of more than this many
+	// bytes go straight from the response to the caller's buffer, without
+	// going through the cache. This keeps an occasional huge read from
+	// growing the cache, while small reads are still cached as usual.
+	StreamThreshold int
+
+	// MaxRetainedCacheBytes, if non-zero, limits the memory the cache
+	// holds on to between reads. Normally the cache's buffer is reused, so
+	// it stays as big as the largest read so far. With a limit, a buffer
+	// that has grown past it is replaced after the read by one just big
+	// enough for its contents. If they are over the limit too, only the
+	// part around the read is kept, up to the limit.
+	MaxRetainedCacheBytes int
+
+	// IfUnmodifiedSince makes requests carry an If-Unmodified-Since header
+	// with the Last-Modified time of the first response, so that reads fail
+	// with ErrContentChanged if the file changes on the server, rather than
+	// mixing data from two versions of it. This suits servers that do not
+	// send strong ETags.
+	IfUnmodifiedSince bool
+
+	// TempFileFallback makes a read that finds the server does not support
+	// ranges save the whole file to a temporary file, and serve all reads
+	// from it after that. This trades one full download for random access
+	// that works, for example to list a zip file. Call Close to remove the
+	// temporary file.
+	TempFileFallback bool
+
+	// Overlay holds bytes to return in place of those of the file, keyed by
+	// their offset, for example to preview local edits to a remote file.
+	// ReadAt, Read and Pipeline return the overlaid bytes where they overlap
+	// the read. ReadAt and Read only fetch from the server when the read is
+	// not entirely covered by the overlay. Where entries overlap, the one
+	// at the higher offset wins. The overlay must lie within the file: it
+	// cannot make the file longer.
+	Overlay map[int64][]byte
+
+	// Fetcher, if set, is asked for the blocks of the file instead of
+	// fetching them directly from the server. See DiskCache.
+	Fetcher BlockFetcher
+
+	// StrictContentRange makes a 206 response without a Content-Range
+	// header an error. Otherwise the response is assumed to start at the
+	// offset asked for, and a warning is logged.
+	StrictContentRange bool
+
+	// FormatRange, if set, formats the Range header value asking for l bytes
+	// starting at from, for servers that do not accept the standard
+	// "bytes=first-last" syntax. Suffix ranges are not affected.
+	FormatRange func(from, l int64) string
+
+	// Header holds extra headers to send with every request, for example
+	// for authentication. Use AddCookie to add cookies to it.
+	Header http.Header
+
+	// PinAddress makes all requests go to the server the first request
+	// connected to, even if the host name resolves to others. See
+	// PinnedDialer for the tradeoffs. It only applies when Client is nil;
+	// to pin the connections of another Client, give its transport a
+	// PinnedDialer.
+	PinAddress bool
+
+	// FirstByteTimeout, if non-zero, limits how long each request waits
+	// for the server to start responding, returning ErrSlowFirstByte if
+	// it takes longer. It does not limit the time taken to read the body.
+	FirstByteTimeout time.Duration
+
+	// MaxRequestsPerOp, if non-zero, limits the number of HTTP requests
+	// made between calls to BeginOp. Further requests fail with
+	// ErrTooManyRequests. This bounds the cost of letting an untrusted
+	// file drive a parser's access pattern.
+	MaxRequestsPerOp int
+
+	// FollowAlternate makes a read that finds the server does not support
+	// ranges look for a Link header with rel="alternate" in the response,
+	// and if there is one, switch to that URL for this and later requests.
+	// Some content services use this to point at a mirror that does
+	// support ranges. The switch is made at most once.
+	FollowAlternate bool
+
+	// MaxResponseBytes, if non-zero, limits how much of each response body
+	// is read. Reads that would need more fail with ErrResponseTooLarge.
+	// This matters when a server ignores the Range header and sends the
+	// whole file, so that reading near the end of a huge file would mean
+	// downloading all of it.
+	MaxResponseBytes int64
+
+	// Trace, if set, is called before each request is sent, and the hooks
+	// of the ClientTrace it returns are called as the request makes
+	// progress, giving the timing of DNS lookups, connecting, the TLS
+	// handshake and the first byte of the response. It may return nil to
+	// skip tracing a request. The request's Range header says what it is
+	// for.
+	//
+	// The hooks are called by net/http's Transport, so they only work with
+	// a Client that uses one, as http.Client does by default. They are
+	// called in addition to those of any ClientTrace already in the
+	// request's context.
+	Trace func(req *http.Request) *httptrace.ClientTrace
+
+	// MaxRetries is how many times to retry a request that gets a 502,
+	// 503 or 504 response, as gateways and proxies sometimes send when the
+	// server behind them is slow or busy. Each retry waits twice as long
+	// as the one before, starting at RetryBackoff, or DefaultRetryBackoff
+	// if that is zero, unless the response has a Retry-After header giving
+	// the number of seconds to wait. No wait is longer than MaxRetryDelay.
+	MaxRetries   int
+	RetryBackoff time.Duration
+
+	// OnFallback, if set, is called when a read has to do something less
+	// efficient than usual, such as downloading the whole file because the
+	// server does not support ranges. kind is one of the Fallback
+	// constants, and detail says more, for people. This lets operators
+	// notice degraded behavior. It may be called from several goroutines
+	// at once, by a Pipeline.
+	OnFallback func(kind, detail string)
+
+	// SlowRequestThreshold, if non-zero, makes requests that take longer
+	// than this to get a response be logged at Info level, with their range
+	// and status. The usual per-request messages move to Debug level, so
+	// that only the slow requests show up in the Info log.
+	SlowRequestThreshold time.Duration
+
+	validated bool
+	ownClient bool // Client was made by init, not set by the user
+
+	etagChecked bool
+
+	sniffing bool // Sniff is reading, so keep its read in the cache
+
+	mu         sync.Mutex // protects the fields below
+	opRequests int
+	etag       string
+	size       int64
+	sizeKnown  bool
+	serverTime time.Time
+	clockSkew  time.Duration
+	lastMod    string
+	maxFetch   int  // largest fetch the server accepts, if limited
+	switched   bool // url is an alternate from a Link header
+	throughput float64
+	spoolFile  *os.File // the whole file, under TempFileFallback
+	conn       *ConnectionInfo
+}
+
+// throughputWeight is the weight of the newest fetch in the moving
+// average reported by Throughput.
+const throughputWeight = 0.3
+
+// Kinds of fallback reported to OnFallback.
+const (
+	// FallbackSizeProbe means a HEAD request did not give the size, so
+	// it was asked for with a range request.
+	FallbackSizeProbe = "size-probe"
+
+	// FallbackFullDownload means the server ignored a range and sent the
+	// whole file.
+	FallbackFullDownload = "full-download"
+
+	// FallbackAlternate means a switch to an alternate URL, under
+	// FollowAlternate.
+	FallbackAlternate = "alternate"
+
+	// FallbackShrinkRange means the server said a range was too large, so
+	// a smaller one was asked for.
+	FallbackShrinkRange = "shrink-range"
+
+	// FallbackRetry means a request is being retried, under MaxRetries.
+	FallbackRetry = "retry"
+)
+
+// statusError is returned for responses with an unexpected status.
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected response status: %v", e.status)
 }
 
 // Compile-time check of interface implementations.
 var _ io.ReadSeeker = (*SeekingHTTP)(nil)
 var _ io.ReaderAt = (*SeekingHTTP)(nil)
+var _ io.Closer = (*SeekingHTTP)(nil)
 
 // New initializes a SeekingHTTP for the given URL.
 // The SeekingHTTP.Client field may be set before the first call
@@ -49,26 +288,124 @@ func (s *SeekingHTTP) SetLogger(logger Logger) {
 	s.Logger = logger
 }
 
+// BeginOp marks the start of a new logical operation, such as listing or
+// extracting from an archive, resetting the count of requests checked
+// against MaxRequestsPerOp.
+func (s *SeekingHTTP) BeginOp() {
+	s.mu.Lock()
+	s.opRequests = 0
+	s.mu.Unlock()
+}
+
+// AddCookie arranges for c to be sent with every request. Only the
+// cookie's name and value are used.
+//
+// The cookie is sent in the Cookie header, which net/http keeps when
+// following a redirect to the same domain or one of its subdomains.
+// If the server redirects to an unrelated domain, use a Client with a
+// cookie jar holding the cookies for that domain instead.
+func (s *SeekingHTTP) AddCookie(c *http.Cookie) {
+	if s.Header == nil {
+		s.Header = make(http.Header)
+	}
+	// Let net/http take care of formatting and joining the cookies.
+	r := http.Request{Header: s.Header}
+	r.AddCookie(c)
+}
+
 func (s *SeekingHTTP) newReq() (*http.Request, error) {
-	var err error
+	// The URL can change under FollowAlternate.
+	s.mu.Lock()
 	if s.url == nil {
-		s.url, err = url.Parse(s.URL)
+		u, err := url.Parse(s.URL)
 		if err != nil {
+			s.mu.Unlock()
 			return nil, err
 		}
+		s.url = u
+	}
+	target := s.url
+	s.mu.Unlock()
+
+	h := s.Header.Clone()
+	if h == nil {
+		h = make(http.Header)
+	}
+	if u := target.User; u != nil && h.Get("Authorization") == "" {
+		// http.Client would do this too, but other HttpClients might not.
+		pass, _ := u.Password()
+		r := http.Request{Header: h}
+		r.SetBasicAuth(u.Username(), pass)
 	}
 	return &http.Request{
 		Method:     "GET",
-		URL:        s.url,
+		URL:        target,
 		Proto:      "HTTP/1.1",
 		ProtoMajor: 1,
 		ProtoMinor: 1,
-		Header:     make(http.Header),
+		Header:     h,
 		Body:       nil,
-		Host:       s.url.Host,
+		Host:       target.Host,
 	}, nil
 }
 
+// DefaultBlockSize is the BlockSize used by a SeekingHTTP that does not
+// set its own. Applications that create many readers can change it once,
+// at startup, before any reads.
+var DefaultBlockSize = 1024 * 1024
+
+func (s *SeekingHTTP) blockSize() int {
+	if s.BlockSize > 0 {
+		return s.BlockSize
+	}
+	if DefaultBlockSize > 0 {
+		return DefaultBlockSize
+	}
+	return 1
+}
+
+// fetchSize returns n, or less if the server has refused ranges that big,
+// as the number of bytes to ask for in one request.
+func (s *SeekingHTTP) fetchSize(n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxFetch > 0 && n > s.maxFetch {
+		return s.maxFetch
+	}
+	return n
+}
+
+// JoinURLPath returns the URL of a file at the unescaped path elem under
+// the base URL, escaping each path segment as needed. This is useful for
+// WebDAV shares, such as Nextcloud, where file names may contain spaces,
+// '#', '?' or '%' that would otherwise be mistaken for parts of the URL.
+// Credentials for basic authentication can be given in the base URL.
+func JoinURLPath(base string, elem ...string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	var segs []string
+	for _, e := range elem {
+		for _, seg := range strings.Split(e, "/") {
+			if seg != "" {
+				segs = append(segs, url.PathEscape(seg))
+			}
+		}
+	}
+	if len(segs) == 0 {
+		return u.String(), nil
+	}
+
+	u.RawPath = strings.TrimSuffix(u.EscapedPath(), "/") + "/" + strings.Join(segs, "/")
+	u.Path, err = url.PathUnescape(u.RawPath)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
 func fmtRange(from, l int64) string {
 	var to int64
 	if l == 0 {
@@ -80,8 +417,30 @@ func fmtRange(from, l int64) string {
 	return fmt.Sprintf("bytes=%v-%v", from, to)
 }
 
+// formatRange returns the Range header value asking for l bytes from
+// offset from, using FormatRange if it is set.
+func (s *SeekingHTTP) formatRange(from, l int64) string {
+	if s.FormatRange != nil {
+		return s.FormatRange(from, l)
+	}
+	return fmtRange(from, l)
+}
+
 // ReadAt reads len(buf) bytes into buf starting at offset off.
-func (s *SeekingHTTP) ReadAt(buf []byte, off int64) (n int, err error) {
+//
+// A response with a status other than 200, 206 or 416 is an error, which
+// includes the status; it is never taken to mean the end of the file. A
+// server that ignores the Range header and answers 200 with the whole file
+// is read from the start, discarding the bytes before off.
+func (s *SeekingHTTP) ReadAt(buf []byte, off int64) (int, error) {
+	if len(s.Overlay) > 0 {
+		return s.readOverlaid(buf, off)
+	}
+	return s.readAt(buf, off)
+}
+
+// readAt is ReadAt without the Overlay.
+func (s *SeekingHTTP) readAt(buf []byte, off int64) (n int, err error) {
 	if s.Logger != nil {
 		s.Logger.Debugf("ReadAt len %v off %v", len(buf), off)
 	}
@@ -90,16 +449,28 @@ func (s *SeekingHTTP) ReadAt(buf []byte, off int64) (n int, err error) {
 		return 0, io.EOF
 	}
 
-	if s.last != nil && off > s.lastOffset {
+	if s.last != nil && off >= s.lastOffset {
 		end := off + int64(len(buf))
-		if end <= s.lastOffset+int64(s.last.Len()) {
+		lastEnd := s.lastOffset + int64(s.last.Len())
+		if end <= lastEnd {
 			start := off - s.lastOffset
 			if s.Logger != nil {
-				s.Logger.Debugf("cache hit: range (%v-%v) is within cache (%v-%v)", off, off+int64(len(buf)), s.lastOffset, s.lastOffset+int64(s.last.Len()))
+				s.Logger.Debugf("cache hit: range (%v-%v) is within cache (%v-%v)", off, off+int64(len(buf)), s.lastOffset, lastEnd)
 			}
 			copy(buf, s.last.Bytes()[start:end-s.lastOffset])
 			return len(buf), nil
 		}
+		if s.lastEOF {
+			// The file ends inside the cache, so there is nothing more
+			// to fetch.
+			if s.Logger != nil {
+				s.Logger.Debugf("cache hit: range (%v-%v) runs past the end of file at %v", off, end, lastEnd)
+			}
+			if off >= lastEnd {
+				return 0, io.EOF
+			}
+			return copy(buf, s.last.Bytes()[off-s.lastOffset:]), io.EOF
+		}
 	}
 
 	if s.Logger != nil {
@@ -110,37 +481,308 @@ func (s *SeekingHTTP) ReadAt(buf []byte, off int64) (n int, err error) {
 		}
 	}
 
-	req, err := s.newReq()
-	if err != nil {
+	if err := s.init(); err != nil {
 		return 0, err
 	}
 
-	// Minimum fetch size is 1 meg
-	wanted := 1024 * 1024
+	if s.Fetcher != nil {
+		n, err := s.readBlocks(buf, off)
+		s.trimCache(off, n)
+		return n, err
+	}
+
+	if s.StreamThreshold > 0 && len(buf) > s.StreamThreshold && !s.sniffing {
+		return s.readStream(buf, off)
+	}
+
+	wanted := s.fetchSize(s.blockSize())
 	if wanted < len(buf) {
 		wanted = len(buf)
 	}
 
-	rng := fmtRange(off, int64(wanted))
-	req.Header.Add("Range", rng)
+	var kept int
+	var eof bool
+	for {
+		kept = s.resetCache(off)
+		var err error
+		_, eof, err = s.fill(context.Background(), s.last, off, wanted, len(buf))
+		if err == nil {
+			break
+		}
+
+		var se *statusError
+		if !errors.As(err, &se) || !s.rangeTooLarge(se.code) || wanted <= len(buf) {
+			return 0, err
+		}
+		wanted /= 2
+		if wanted < len(buf) {
+			wanted = len(buf)
+		}
+		if s.Logger != nil {
+			s.Logger.Infof("Range too large (status %v), trying %v bytes", se.code, wanted)
+		}
+		s.fallback(FallbackShrinkRange, "status %v, trying %v bytes", se.code, wanted)
+		s.mu.Lock()
+		s.maxFetch = wanted
+		s.mu.Unlock()
+	}
+	if s.Logger != nil {
+		s.Logger.Debugf("loaded %d bytes into last", s.last.Len()-kept)
+	}
+
+	got := s.last.Bytes()[kept:]
+	s.lastEOF = eof
+	if s.lastEOF {
+		s.inferSize(off, len(got))
+	}
+
+	n = copy(buf, got)
+	s.trimCache(off, n)
+	if n < len(buf) {
+		return n, io.EOF
+	}
+	return n, nil
+}
 
+// trimCache releases the cache's buffer if it is bigger than
+// MaxRetainedCacheBytes. If the contents are over the limit too, only
+// that much of them is kept, around the read of n bytes at off that just
+// filled the cache: from off, or from just after the read if it was bigger
+// than the limit, so that a sequential reader still finds its next bytes.
+func (s *SeekingHTTP) trimCache(off int64, n int) {
+	limit := s.MaxRetainedCacheBytes
+	if limit <= 0 || s.last == nil || s.last.Cap() <= limit {
+		return
+	}
+	data := s.last.Bytes()
+	if len(data) > limit {
+		lastEnd := s.lastOffset + int64(len(data))
+		from := off
+		if n > limit {
+			from = off + int64(n)
+		}
+		if from < s.lastOffset {
+			from = s.lastOffset
+		}
+		if from > lastEnd-int64(limit) {
+			from = lastEnd - int64(limit)
+		}
+		if s.Logger != nil {
+			s.Logger.Debugf("keeping %v of %v bytes of cache, from %v, under MaxRetainedCacheBytes", limit, len(data), from)
+		}
+		start := from - s.lastOffset
+		data = data[start : start+int64(limit)]
+		s.lastOffset = from
+		s.lastEOF = s.lastEOF && from+int64(limit) == lastEnd
+	}
+	kept := make([]byte, len(data))
+	copy(kept, data)
+	s.last = bytes.NewBuffer(kept)
+}
+
+// readStream reads straight into buf, leaving the cache alone.
+func (s *SeekingHTTP) readStream(buf []byte, off int64) (int, error) {
+	if s.Logger != nil {
+		s.Logger.Debugf("streaming read of %v bytes, bypassing cache", len(buf))
+	}
+	w := &sliceWriter{buf: buf}
+	_, eof, err := s.fill(context.Background(), w, off, len(buf), len(buf))
+	if err != nil {
+		return w.n, err
+	}
+	if w.n < len(buf) {
+		if eof {
+			s.inferSize(off, w.n)
+		}
+		return w.n, io.EOF
+	}
+	return w.n, nil
+}
+
+// sliceWriter writes into buf, which must be big enough.
+type sliceWriter struct {
+	buf []byte
+	n   int
+}
+
+func (w *sliceWriter) Write(p []byte) (int, error) {
+	n := copy(w.buf[w.n:], p)
+	w.n += n
+	if n < len(p) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+// ReadFrom reads directly into buf, so that io.Copy needs no buffer of
+// its own.
+func (w *sliceWriter) ReadFrom(r io.Reader) (int64, error) {
+	n, err := io.ReadFull(r, w.buf[w.n:])
+	w.n += n
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return int64(n), err
+}
+
+// rangeTooLarge reports whether a response with the status code means the
+// range asked for was too large.
+func (s *SeekingHTTP) rangeTooLarge(code int) bool {
+	codes := s.RangeTooLargeStatus
+	if codes == nil {
+		codes = []int{
+			http.StatusRequestEntityTooLarge,
+			http.StatusRequestURITooLong,
+			http.StatusRequestHeaderFieldsTooLarge,
+		}
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// resetCache empties the cache, ready to append data starting at off to it.
+// Up to KeepBehindBytes of the old contents just before off are kept.
+// It returns the number of bytes kept.
+func (s *SeekingHTTP) resetCache(off int64) int {
+	s.lastEOF = false
 	if s.last == nil {
 		// Cache does not exist yet. So make it.
 		s.last = &bytes.Buffer{}
-	} else {
-		// Cache is getting replaced. Bring it back to zero bytes, but
-		// keep the underlying []byte, since we'll reuse it right away.
-		s.last.Reset()
+		s.lastOffset = off
+		return 0
 	}
 
-	if s.Logger != nil {
-		s.Logger.Infof("Start HTTP GET with Range: %s", rng)
+	var kept []byte
+	lastEnd := s.lastOffset + int64(s.last.Len())
+	if k := int64(s.KeepBehindBytes); k > 0 && s.lastOffset < off && off <= lastEnd {
+		from := off - k
+		if from < s.lastOffset {
+			from = s.lastOffset
+		}
+		kept = append(kept, s.last.Bytes()[from-s.lastOffset:off-s.lastOffset]...)
+	}
+
+	// Cache is getting replaced. Bring it back to zero bytes, but
+	// keep the underlying []byte, since we'll reuse it right away.
+	s.last.Reset()
+	s.last.Write(kept)
+	s.lastOffset = off - int64(len(kept))
+	return len(kept)
+}
+
+// readBlocks fills buf using s.Fetcher, one block at a time. The last
+// block it gets is kept in the cache.
+func (s *SeekingHTTP) readBlocks(buf []byte, off int64) (int, error) {
+	bs := int64(s.blockSize())
+	n := 0
+	for n < len(buf) {
+		pos := off + int64(n)
+		index := pos / bs
+		data, err := s.Fetcher.FetchBlock(s, index, func() ([]byte, error) {
+			data, _, err := s.FetchBlock(index)
+			if err == io.EOF {
+				err = nil
+			}
+			return data, err
+		})
+		if err != nil {
+			return n, err
+		}
+
+		s.resetCache(index * bs)
+		s.last.Write(data)
+		s.lastEOF = int64(len(data)) < bs
+		if s.lastEOF {
+			s.inferSize(index*bs, len(data))
+		}
+
+		start := pos - index*bs
+		if start >= int64(len(data)) {
+			return n, io.EOF
+		}
+		n += copy(buf[n:], data[start:])
+		if s.lastEOF && n < len(buf) {
+			return n, io.EOF
+		}
 	}
+	return n, nil
+}
 
+// FetchBlock fetches block index of the file from the server, where block i
+// is the BlockSize bytes starting at offset i*BlockSize. It returns the
+// block and its offset. The last block of the file may be short. Asking for
+// a block after the last one returns io.EOF, without making a request if
+// the size of the file is already known.
+//
+// FetchBlock is meant for building caches that work in blocks. It always
+// goes to the server, bypassing both the in-memory cache and Fetcher.
+func (s *SeekingHTTP) FetchBlock(index int64) ([]byte, int64, error) {
+	if index < 0 {
+		return nil, 0, errors.New("negative block index")
+	}
 	if err := s.init(); err != nil {
+		return nil, 0, err
+	}
+
+	bs := int64(s.blockSize())
+	off := index * bs
+	n := bs
+	if size, ok := s.SizeKnown(); ok {
+		if off >= size {
+			return nil, off, io.EOF
+		}
+		if size-off < n {
+			n = size - off
+		}
+	}
+
+	data, err := s.fetch(context.Background(), off, int(n))
+	if err != nil {
+		return nil, off, err
+	}
+	if len(data) == 0 {
+		return nil, off, io.EOF
+	}
+	return data, off, nil
+}
+
+// fetch gets up to n bytes starting at off from the server. It returns
+// fewer than n bytes if the file ends first. Unlike ReadAt, it does not
+// use the cache, so it is safe to call from several goroutines at once,
+// once s.init and s.newReq have been called.
+func (s *SeekingHTTP) fetch(ctx context.Context, off int64, n int) ([]byte, error) {
+	var b bytes.Buffer
+	if _, _, err := s.fill(ctx, &b, off, n, n); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// fetchInto is like fetch, but writes the bytes to dst, returning how many
+// it wrote. It makes a single request, so it may write fewer than n bytes
+// even when the file does not end first; see fill.
+func (s *SeekingHTTP) fetchInto(ctx context.Context, dst io.Writer, off int64, n int) (written int64, err error) {
+	if f := s.spooled(); f != nil {
+		return io.Copy(dst, io.NewSectionReader(f, off, int64(n)))
+	}
+
+	req, err := s.newReq()
+	if err != nil {
 		return 0, err
 	}
-	resp, err := s.Client.Do(req)
+	req = req.WithContext(ctx)
+
+	rng := s.formatRange(off, int64(n))
+	req.Header.Add("Range", rng)
+
+	s.logRequestf("Start HTTP GET with Range: %s", rng)
+
+	start := time.Now()
+	resp, err := s.do(req)
 	if err != nil {
 		return 0, err
 	}
@@ -153,58 +795,522 @@ func (s *SeekingHTTP) ReadAt(buf []byte, off int64) (n int, err error) {
 		}
 	}(resp.Body)
 
-	if s.Logger != nil {
-		s.Logger.Infof("Response status: %v", resp.StatusCode)
-	}
+	s.logRequestf("Response status: %v", resp.StatusCode)
 
-	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent {
-		_, err := s.last.ReadFrom(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if v := resp.Header.Get("Content-Range"); v != "" {
+			cr, err := parseContentRange(v)
+			if err != nil {
+				return 0, err
+			}
+			if cr.first != off {
+				return 0, fmt.Errorf("server sent range starting at %v, asked for %v", cr.first, off)
+			}
+		} else if s.StrictContentRange {
+			return 0, errors.New("206 response without Content-Range")
+		} else if s.Logger != nil {
+			s.Logger.Infof("Warning: 206 response without Content-Range, assuming it starts at %v", off)
+		}
+		got, err := io.Copy(dst, io.LimitReader(resp.Body, int64(n)))
+		s.noteThroughput(got, time.Since(start))
+		return got, err
+	case http.StatusOK:
+		// The server ignored the range and is sending the whole file.
+		if s.FollowAlternate && s.switchToAlternate(resp) {
+			resp.Body.Close()
+			return s.fetchInto(ctx, dst, off, n)
+		}
+		if s.TempFileFallback {
+			f, err := s.spool(resp.Body)
+			if err != nil {
+				return 0, err
+			}
+			return io.Copy(dst, io.NewSectionReader(f, off, int64(n)))
+		}
+		s.fallback(FallbackFullDownload, "server ignored Range: %v, reading from the start of the file", rng)
+		body := s.limitBody(resp.Body)
+		skipped, err := io.CopyN(io.Discard, body, off)
 		if err != nil {
+			s.noteThroughput(skipped, time.Since(start))
+			if err == io.EOF {
+				return 0, nil
+			}
 			return 0, err
 		}
+		got, err := io.Copy(dst, io.LimitReader(body, int64(n)))
+		s.noteThroughput(skipped+got, time.Since(start))
+		return got, err
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Nothing there: off is at or past the end of the file.
+		return 0, nil
+	}
+	return 0, &statusError{code: resp.StatusCode, status: resp.Status}
+}
+
+// fill is like fetchInto, but when a server sends fewer bytes than asked
+// for before the end of the file, as CDNs that cap the size of their
+// responses do, it asks for the rest, until at least need of the n bytes
+// are written. No request asks for more than fetchSize allows. It returns
+// the number of bytes written and whether they run to the end of the file.
+func (s *SeekingHTTP) fill(ctx context.Context, dst io.Writer, off int64, n, need int) (int, bool, error) {
+	got := 0
+	for {
+		asked := s.fetchSize(n - got)
+		m, err := s.fetchInto(ctx, dst, off+int64(got), asked)
+		got += int(m)
+		if err != nil {
+			return got, false, err
+		}
+		if s.atEOF(off+int64(got), int(m) < asked) {
+			return got, true, nil
+		}
+		if got >= need {
+			return got, false, nil
+		}
+		if m == 0 {
+			return got, false, io.ErrUnexpectedEOF
+		}
 		if s.Logger != nil {
-			s.Logger.Debugf("loaded %d bytes into last", s.last.Len())
+			s.Logger.Debugf("got %v of %v bytes at %v, asking for the rest", got, n, off)
 		}
+	}
+}
 
-		s.lastOffset = off
-		var n int
-		if s.last.Len() < len(buf) {
-			n = s.last.Len()
-			copy(buf, s.last.Bytes()[0:n])
-		} else {
-			n = len(buf)
-			copy(buf, s.last.Bytes())
-		}
+// atEOF reports whether end, the offset just past the bytes fetched, is the
+// end of the file. The size of the file, once a response has given it,
+// decides. Until then, short says whether the server sent fewer bytes than
+// asked for, which is taken to mean the file ended.
+func (s *SeekingHTTP) atEOF(end int64, short bool) bool {
+	if size, ok := s.SizeKnown(); ok {
+		return end >= size
+	}
+	return short
+}
 
-		// HTTP is trying to tell us, "that's all". Which is fine, but we don't
-		// want callers to think it is EOF, it's not.
-		if err == io.EOF && n == len(buf) {
-			err = nil
-		}
+// limitBody returns a reader for body that fails with ErrResponseTooLarge
+// rather than read more than MaxResponseBytes from it.
+func (s *SeekingHTTP) limitBody(body io.Reader) io.Reader {
+	if s.MaxResponseBytes <= 0 {
+		return body
+	}
+	return &maxBytesReader{r: body, n: s.MaxResponseBytes}
+}
 
+// maxBytesReader reads up to n bytes from r, then fails with
+// ErrResponseTooLarge if there are more.
+type maxBytesReader struct {
+	r io.Reader
+	n int64 // bytes left
+}
+
+func (m *maxBytesReader) Read(buf []byte) (int, error) {
+	// Ask for one byte more than allowed, to see if there is more.
+	if int64(len(buf)) > m.n+1 {
+		buf = buf[:m.n+1]
+	}
+	n, err := m.r.Read(buf)
+	if int64(n) <= m.n {
+		m.n -= int64(n)
 		return n, err
 	}
-	return 0, io.EOF
+	n = int(m.n)
+	m.n = 0
+	return n, ErrResponseTooLarge
+}
+
+// switchToAlternate makes later requests go to the URL in the first
+// rel="alternate" Link header of resp, reporting whether it did.
+func (s *SeekingHTTP) switchToAlternate(resp *http.Response) bool {
+	alt := alternateLink(resp.Header)
+	if alt == "" {
+		return false
+	}
+
+	s.mu.Lock()
+	base := s.url
+	if resp.Request != nil {
+		// After any redirects.
+		base = resp.Request.URL
+	}
+	u, err := base.Parse(alt)
+	if err != nil || s.switched || u.String() == s.url.String() {
+		s.mu.Unlock()
+		return false
+	}
+	s.url = u
+	s.switched = true
+	s.mu.Unlock()
+
+	if s.Logger != nil {
+		s.Logger.Infof("Server does not support ranges, switching to alternate %v", u.Redacted())
+	}
+	s.fallback(FallbackAlternate, "switching to %v", u.Redacted())
+	return true
+}
+
+// alternateLink returns the target of the first Link header entry with a
+// rel of "alternate", or "" if there is none.
+func alternateLink(h http.Header) string {
+	for _, v := range h.Values("Link") {
+		for _, link := range strings.Split(v, ",") {
+			link = strings.TrimSpace(link)
+			if !strings.HasPrefix(link, "<") {
+				continue
+			}
+			target, params, ok := strings.Cut(link[1:], ">")
+			if !ok {
+				continue
+			}
+			for _, p := range strings.Split(params, ";") {
+				k, v, _ := strings.Cut(p, "=")
+				if !strings.EqualFold(strings.TrimSpace(k), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(v), `"`)) {
+					if strings.EqualFold(rel, "alternate") {
+						return target
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Throughput returns an estimate of the current download speed in bytes
+// per second, or 0 if nothing has been fetched yet. It is a moving average
+// over recent fetches, weighted towards the latest, of the bytes received
+// divided by the time from sending the request to reading the last byte.
+func (s *SeekingHTTP) Throughput() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.throughput
+}
+
+// noteThroughput adds a fetch of n bytes that took d to the Throughput
+// estimate.
+func (s *SeekingHTTP) noteThroughput(n int64, d time.Duration) {
+	if n <= 0 || d <= 0 {
+		return
+	}
+	rate := float64(n) / d.Seconds()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.throughput == 0 {
+		s.throughput = rate
+	} else {
+		s.throughput += throughputWeight * (rate - s.throughput)
+	}
+}
+
+// Validate checks that the configuration of s makes sense. It is called
+// before the first request, so there is no need to call it directly except
+// to check the configuration early. Changes made after the first request
+// are not checked.
+func (s *SeekingHTTP) Validate() error {
+	if s.URL == "" {
+		return errors.New("URL is empty")
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("URL %q is not absolute", s.URL)
+	}
+	if s.PinAddress && s.Client != nil && !s.ownClient {
+		return errors.New("PinAddress cannot be used with a Client, use a PinnedDialer instead")
+	}
+	if s.BlockSize < 0 {
+		return fmt.Errorf("BlockSize must not be negative, got %v", s.BlockSize)
+	}
+	if s.KeepBehindBytes < 0 {
+		return fmt.Errorf("KeepBehindBytes must not be negative, got %v", s.KeepBehindBytes)
+	}
+	if s.SniffLength < 0 {
+		return fmt.Errorf("SniffLength must not be negative, got %v", s.SniffLength)
+	}
+	if s.StreamThreshold < 0 {
+		return fmt.Errorf("StreamThreshold must not be negative, got %v", s.StreamThreshold)
+	}
+	if s.MaxRetainedCacheBytes < 0 {
+		return fmt.Errorf("MaxRetainedCacheBytes must not be negative, got %v", s.MaxRetainedCacheBytes)
+	}
+	if s.MaxResponseBytes < 0 {
+		return fmt.Errorf("MaxResponseBytes must not be negative, got %v", s.MaxResponseBytes)
+	}
+	if s.FirstByteTimeout < 0 {
+		return fmt.Errorf("FirstByteTimeout must not be negative, got %v", s.FirstByteTimeout)
+	}
+	if s.MaxRequestsPerOp < 0 {
+		return fmt.Errorf("MaxRequestsPerOp must not be negative, got %v", s.MaxRequestsPerOp)
+	}
+	if s.MaxRetries < 0 {
+		return fmt.Errorf("MaxRetries must not be negative, got %v", s.MaxRetries)
+	}
+	if s.RetryBackoff < 0 {
+		return fmt.Errorf("RetryBackoff must not be negative, got %v", s.RetryBackoff)
+	}
+	if s.SlowRequestThreshold < 0 {
+		return fmt.Errorf("SlowRequestThreshold must not be negative, got %v", s.SlowRequestThreshold)
+	}
+	return nil
 }
 
 // If they did not give us an HTTP Client, use the default one.
 func (s *SeekingHTTP) init() error {
+	if !s.validated {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+		s.validated = true
+	}
+
 	if s.Client == nil {
-		s.Client = http.DefaultClient
+		if s.PinAddress {
+			s.Client = pinnedClient(&PinnedDialer{})
+		} else {
+			s.Client = http.DefaultClient
+		}
+		s.ownClient = true
 	}
 
 	return nil
 }
 
+// fallback reports a fallback to OnFallback, if it is set.
+func (s *SeekingHTTP) fallback(kind, format string, args ...interface{}) {
+	if s.OnFallback != nil {
+		s.OnFallback(kind, fmt.Sprintf(format, args...))
+	}
+}
+
+// logRequestf logs the progress of a request. It goes to the Info log,
+// unless SlowRequestThreshold is set.
+func (s *SeekingHTTP) logRequestf(format string, args ...interface{}) {
+	if s.Logger == nil {
+		return
+	}
+	if s.SlowRequestThreshold > 0 {
+		s.Logger.Debugf(format, args...)
+	} else {
+		s.Logger.Infof(format, args...)
+	}
+}
+
+// do sends req with s.Client, retrying as allowed by MaxRetries.
+func (s *SeekingHTTP) do(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := s.doOnce(req)
+		if err != nil || attempt >= s.MaxRetries || !retryable(resp.StatusCode) {
+			return resp, err
+		}
+		resp.Body.Close()
+
+		delay := s.retryDelay(attempt, resp)
+		if s.Logger != nil {
+			s.Logger.Infof("%v with Range: %q got status %v, retrying in %v", req.Method, req.Header.Get("Range"), resp.StatusCode, delay)
+		}
+		s.fallback(FallbackRetry, "%v with Range: %q got status %v, retry %v", req.Method, req.Header.Get("Range"), resp.StatusCode, attempt+1)
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-req.Context().Done():
+			t.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryable reports whether a response with the status code is worth
+// retrying.
+func retryable(code int) bool {
+	switch code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// DefaultRetryBackoff is the RetryBackoff used by a SeekingHTTP that does
+// not set its own.
+var DefaultRetryBackoff = time.Second
+
+// MaxRetryDelay is the longest a retry waits, whatever the backoff or the
+// Retry-After header say.
+var MaxRetryDelay = time.Minute
+
+// retryDelay returns how long to wait before retrying after the given
+// attempt got resp.
+func (s *SeekingHTTP) retryDelay(attempt int, resp *http.Response) time.Duration {
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+		if int64(secs) > int64(MaxRetryDelay/time.Second) {
+			return MaxRetryDelay
+		}
+		return time.Duration(secs) * time.Second
+	}
+	d := s.RetryBackoff
+	if d <= 0 {
+		d = DefaultRetryBackoff
+	}
+	// Double one step at a time, so a large attempt cannot overflow.
+	for i := 0; i < attempt && d < MaxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > MaxRetryDelay {
+		d = MaxRetryDelay
+	}
+	return d
+}
+
+// doOnce sends req with s.Client, applying MaxRequestsPerOp and
+// SlowRequestThreshold.
+func (s *SeekingHTTP) doOnce(req *http.Request) (*http.Response, error) {
+	s.mu.Lock()
+	if s.MaxRequestsPerOp > 0 && s.opRequests >= s.MaxRequestsPerOp {
+		s.mu.Unlock()
+		return nil, ErrTooManyRequests
+	}
+	s.opRequests++
+	lastMod := s.lastMod
+	s.mu.Unlock()
+
+	if s.IfUnmodifiedSince && lastMod != "" {
+		req.Header.Set("If-Unmodified-Since", lastMod)
+	}
+	if s.Trace != nil {
+		if trace := s.Trace(req); trace != nil {
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+		}
+	}
+
+	start := time.Now()
+	resp, err := s.doFirstByte(req)
+	if err == nil && resp.StatusCode == http.StatusPreconditionFailed && s.IfUnmodifiedSince {
+		resp.Body.Close()
+		return nil, ErrContentChanged
+	}
+	if err == nil {
+		s.noteResponse(resp)
+	}
+	if elapsed := time.Since(start); s.SlowRequestThreshold > 0 && elapsed > s.SlowRequestThreshold && s.Logger != nil {
+		if err != nil {
+			s.Logger.Infof("Slow %v with Range: %q failed after %v: %v", req.Method, req.Header.Get("Range"), elapsed, err)
+		} else {
+			s.Logger.Infof("Slow %v with Range: %q took %v, status: %v", req.Method, req.Header.Get("Range"), elapsed, resp.StatusCode)
+		}
+	}
+	return resp, err
+}
+
+// noteResponse records what resp tells us about the file and the server.
+func (s *SeekingHTTP) noteResponse(resp *http.Response) {
+	size, sizeOK := sizeFromResponse(resp)
+	date, dateErr := http.ParseTime(resp.Header.Get("Date"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sizeOK {
+		s.size, s.sizeKnown = size, true
+	}
+	if resp.StatusCode/100 == 2 {
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			s.etag = etag
+		}
+		// Keep the first one, to notice when it changes.
+		if s.lastMod == "" {
+			s.lastMod = resp.Header.Get("Last-Modified")
+		}
+	}
+	if dateErr == nil {
+		s.serverTime = date
+		s.clockSkew = date.Sub(time.Now())
+	}
+	if s.conn == nil {
+		s.conn = &ConnectionInfo{
+			Proto:      resp.Proto,
+			ProtoMajor: resp.ProtoMajor,
+			ProtoMinor: resp.ProtoMinor,
+			TLS:        resp.TLS,
+		}
+	}
+}
+
+// ConnectionInfo describes the connection to the server.
+type ConnectionInfo struct {
+	// Proto, ProtoMajor and ProtoMinor give the HTTP version, such as
+	// "HTTP/2.0", 2 and 0.
+	Proto      string
+	ProtoMajor int
+	ProtoMinor int
+
+	// TLS holds the TLS version, cipher suite and so on, or nil if the
+	// connection is not encrypted.
+	TLS *tls.ConnectionState
+}
+
+// ConnectionInfo returns the HTTP version and TLS details of the
+// connection used for the first response, and false if there has been no
+// response yet. This helps explain differences in performance between
+// servers. The HttpClient must fill in the Proto and TLS fields of its
+// responses, as http.Client does.
+func (s *SeekingHTTP) ConnectionInfo() (ConnectionInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return ConnectionInfo{}, false
+	}
+	return *s.conn, true
+}
+
+// doFirstByte sends req with s.Client, applying FirstByteTimeout.
+func (s *SeekingHTTP) doFirstByte(req *http.Request) (*http.Response, error) {
+	if s.FirstByteTimeout <= 0 {
+		return s.Client.Do(req)
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(s.FirstByteTimeout, cancel)
+	resp, err := s.Client.Do(req.WithContext(ctx))
+	if !timer.Stop() {
+		// The timer fired, so the request was cancelled (or is about to be).
+		cancel()
+		if err == nil {
+			resp.Body.Close()
+		}
+		return nil, ErrSlowFirstByte
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// The context must live until the body has been read.
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose cancels a request's context when its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
 func (s *SeekingHTTP) Read(buf []byte) (int, error) {
 	if s.Logger != nil {
 		s.Logger.Debugf("got read len %v", len(buf))
 	}
 
 	n, err := s.ReadAt(buf, s.offset)
-	if err == nil {
-		s.offset += int64(n)
-	}
+	s.offset += int64(n)
 
 	return n, err
 }
@@ -229,29 +1335,276 @@ func (s *SeekingHTTP) Seek(offset int64, whence int) (int64, error) {
 	return s.offset, nil
 }
 
-// Size uses an HTTP HEAD to find out how many bytes are available in total.
-func (s *SeekingHTTP) Size() (int64, error) {
+// ReadSuffix uses a suffix byte range to fetch the last n bytes of the file
+// without needing to know its size. It returns the bytes and the offset
+// in the file where they start. If the file is shorter than n bytes, all
+// of it is returned. If the server has refused ranges as big as n, the
+// last of them is fetched with a suffix range, and the rest with ordinary
+// ranges no bigger than it accepts.
+func (s *SeekingHTTP) ReadSuffix(n int64) ([]byte, int64, error) {
+	if n <= 0 {
+		return nil, 0, errors.New("suffix length must be positive")
+	}
+
+	m := int64(s.fetchSize(int(n)))
+	buf, start, err := s.readSuffix(m)
+	if err != nil || m == n || int64(len(buf)) < m || start == 0 {
+		return buf, start, err
+	}
+
+	from := start - (n - m)
+	if from < 0 {
+		from = 0
+	}
+	var b bytes.Buffer
+	l := int(start - from)
+	if _, _, err := s.fill(context.Background(), &b, from, l, l); err != nil {
+		return nil, 0, err
+	}
+	b.Write(buf)
+	return b.Bytes(), from, nil
+}
+
+// readSuffix is ReadSuffix with a single request.
+func (s *SeekingHTTP) readSuffix(n int64) ([]byte, int64, error) {
 	if err := s.init(); err != nil {
-		return 0, err
+		return nil, 0, err
+	}
+	if f := s.spooled(); f != nil {
+		return readSuffixFile(f, n)
 	}
 
 	req, err := s.newReq()
 	if err != nil {
-		return 0, err
+		return nil, 0, err
 	}
-	req.Method = "HEAD"
 
-	resp, err := s.Client.Do(req)
+	rng := fmt.Sprintf("bytes=-%v", n)
+	req.Header.Add("Range", rng)
+
+	s.logRequestf("Start HTTP GET with Range: %s", rng)
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	s.logRequestf("Response status: %v", resp.StatusCode)
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		cr, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return nil, 0, err
+		}
+		if cr.first < 0 {
+			return nil, 0, fmt.Errorf("no range in Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		buf, err := io.ReadAll(io.LimitReader(s.limitBody(resp.Body), n))
+		if err != nil {
+			return nil, 0, err
+		}
+		return buf, cr.first, nil
+	case http.StatusOK:
+		// The server ignored the range and sent the whole file.
+		if s.TempFileFallback {
+			f, err := s.spool(resp.Body)
+			if err != nil {
+				return nil, 0, err
+			}
+			return readSuffixFile(f, n)
+		}
+		s.fallback(FallbackFullDownload, "server ignored Range: %v, reading the whole file", rng)
+		buf, err := io.ReadAll(s.limitBody(resp.Body))
+		if err != nil {
+			return nil, 0, err
+		}
+		if int64(len(buf)) > n {
+			start := int64(len(buf)) - n
+			return buf[start:], start, nil
+		}
+		return buf, 0, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Nothing to send: the file is empty.
+		return nil, 0, nil
+	}
+	return nil, 0, fmt.Errorf("unexpected response status for suffix range: %v", resp.Status)
+}
+
+// Warmup makes a cheap request, for the first byte of the file, so that
+// the client has a connection to the server ready for the first real read.
+// The connection is only kept for reuse if the client's transport does
+// keep-alives, as http.DefaultTransport does. A response with an
+// unexpected status, such as 404, is returned as an error, so Warmup also
+// checks that the file is there.
+func (s *SeekingHTTP) Warmup(ctx context.Context) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+
+	req, err := s.newReq()
 	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("Range", s.formatRange(0, 1))
+
+	s.logRequestf("Warming up connection to %v", req.URL.Host)
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 416 is what an empty file gets.
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		return &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	// The connection can only be reused once the body has been read. In
+	// case the server ignored the range, don't read too much of it.
+	_, err = io.CopyN(io.Discard, resp.Body, 64*1024)
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
+
+// ETag returns the ETag of the file, as sent by the server in the most
+// recent response. If there has not been a response yet, it makes a HEAD
+// request to find out. It returns "" if the server does not send ETags.
+func (s *SeekingHTTP) ETag() (string, error) {
+	s.mu.Lock()
+	etag := s.etag
+	s.mu.Unlock()
+	if etag != "" || s.etagChecked {
+		return etag, nil
+	}
+
+	resp, err := s.head()
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	s.etagChecked = true
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.etag, nil
+}
+
+// ServerTime returns the time in the Date header of the most recent response
+// that had one, or the zero Time if none has.
+func (s *SeekingHTTP) ServerTime() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.serverTime
+}
+
+// ClockSkew estimates how far the server's clock is ahead of the local
+// clock, from the Date header of the most recent response that had one.
+// This is useful for knowing when a pre-signed URL will expire according to
+// the server. Since the Date header only has a resolution of one second,
+// so does the estimate. It is zero if no response had a Date header.
+func (s *SeekingHTTP) ClockSkew() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clockSkew
+}
+
+// Size uses an HTTP HEAD to find out how many bytes are available in total.
+// If the size is already known from an earlier response, no request is made.
+func (s *SeekingHTTP) Size() (int64, error) {
+	if size, ok := s.SizeKnown(); ok {
+		return size, nil
+	}
+
+	resp, err := s.head()
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK && resp.ContentLength >= 0 {
+		if s.Logger != nil {
+			// A custom Client may not set resp.Request.
+			u := s.URL
+			if resp.Request != nil {
+				u = resp.Request.URL.String()
+			}
+			s.Logger.Debugf("url: %v, size %v", u, resp.ContentLength)
+		}
+		return resp.ContentLength, nil
+	}
+
+	// Some servers do not allow HEAD, or do not say the length in reply to
+	// it. Ask for the first byte instead, and get the size from the
+	// Content-Range of the response.
+	s.fallback(FallbackSizeProbe, "HEAD gave status %v, content length %v", resp.StatusCode, resp.ContentLength)
+	if _, err := s.fetch(context.Background(), 0, 1); err != nil {
 		return 0, err
 	}
+	if size, ok := s.SizeKnown(); ok {
+		return size, nil
+	}
+	return 0, errors.New("no content length for Size()")
+}
+
+// SizeKnown returns the size of the file and true if it is already known,
+// from Size or from the responses to earlier reads. It never makes a
+// request.
+func (s *SeekingHTTP) SizeKnown() (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size, s.sizeKnown
+}
+
+func (s *SeekingHTTP) setSize(size int64) {
+	s.mu.Lock()
+	s.size, s.sizeKnown = size, true
+	s.mu.Unlock()
+}
+
+// inferSize records the size of the file as off+n, after a read of n bytes
+// at off came up short, unless a response has already given the size. An
+// empty read says nothing, since off may be past the end of the file.
+func (s *SeekingHTTP) inferSize(off int64, n int) {
+	if n == 0 {
+		return
+	}
+	s.mu.Lock()
+	if !s.sizeKnown {
+		s.size, s.sizeKnown = off+int64(n), true
+	}
+	s.mu.Unlock()
+}
 
-	if resp.ContentLength < 0 {
-		return 0, errors.New("no content length for Size()")
+// sizeFromResponse gets the size of the whole file from resp, if it says.
+func sizeFromResponse(resp *http.Response) (int64, bool) {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// A zero ContentLength may just be one that was never set.
+		return resp.ContentLength, resp.ContentLength > 0
+	case http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		cr, err := parseContentRange(resp.Header.Get("Content-Range"))
+		return cr.size, err == nil && cr.size >= 0
 	}
+	return 0, false
+}
 
-	if s.Logger != nil {
-		s.Logger.Debugf("url: %v, size %v", req.URL.String(), resp.ContentLength)
+// head makes a HEAD request for the file.
+func (s *SeekingHTTP) head() (*http.Response, error) {
+	if err := s.init(); err != nil {
+		return nil, err
 	}
-	return resp.ContentLength, nil
+
+	req, err := s.newReq()
+	if err != nil {
+		return nil, err
+	}
+	req.Method = "HEAD"
+
+	return s.do(req)
 }
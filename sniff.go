@@ -0,0 +1,64 @@
+package seekinghttp
+
+import (
+	"bytes"
+	"io"
+)
+
+// Formats recognized by Sniff.
+const (
+	FormatUnknown = ""
+	FormatTar     = "tar"
+	FormatZip     = "zip"
+	FormatGzip    = "gzip"
+	FormatBGZF    = "bgzf"
+	FormatParquet = "parquet"
+)
+
+// DefaultSniffLength is the SniffLength used by a SeekingHTTP that does
+// not set its own. It is enough to see the magic number of a tar file,
+// which is at offset 257.
+const DefaultSniffLength = 512
+
+// Sniff reads the start of the file to tell what kind of file it is,
+// returning one of the Format constants. It reads SniffLength bytes, or
+// DefaultSniffLength if that is zero, through the cache, so a read of the
+// same bytes afterwards, such as to read the first tar header, does not
+// fetch them again. This holds even if StreamThreshold is less than the
+// sniff length. A SniffLength of less than 263 bytes cannot recognize tar
+// files.
+func (s *SeekingHTTP) Sniff() (string, error) {
+	buf := make([]byte, s.sniffLength())
+	s.sniffing = true
+	n, err := s.ReadAt(buf, 0)
+	s.sniffing = false
+	if err != nil && err != io.EOF {
+		return FormatUnknown, err
+	}
+	return sniff(buf[:n]), nil
+}
+
+func (s *SeekingHTTP) sniffLength() int {
+	if s.SniffLength > 0 {
+		return s.SniffLength
+	}
+	return DefaultSniffLength
+}
+
+// sniff tells what kind of file starts with buf.
+func sniff(buf []byte) string {
+	switch {
+	case bytes.HasPrefix(buf, []byte("PK\x03\x04")), bytes.HasPrefix(buf, []byte("PK\x05\x06")):
+		return FormatZip
+	case bytes.HasPrefix(buf, []byte("PAR1")):
+		return FormatParquet
+	case bytes.HasPrefix(buf, bgzfEOF[:4]) && len(buf) >= 16 && bytes.Equal(buf[12:16], bgzfEOF[12:16]):
+		return FormatBGZF
+	case bytes.HasPrefix(buf, []byte{0x1f, 0x8b}):
+		return FormatGzip
+	case len(buf) >= 263 && (bytes.Equal(buf[257:263], []byte("ustar\x00")) || bytes.Equal(buf[257:263], []byte("ustar "))):
+		// The POSIX magic, or the GNU one.
+		return FormatTar
+	}
+	return FormatUnknown
+}
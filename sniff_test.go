@@ -0,0 +1,104 @@
+package seekinghttp
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSniff(t *testing.T) {
+	tarData, _ := makeTar(t, []tarFile{{"a.txt", "hello"}})
+
+	var zipData bytes.Buffer
+	zw := zip.NewWriter(&zipData)
+	zw.Create("a.txt")
+	zw.Close()
+
+	var gzData bytes.Buffer
+	gw := gzip.NewWriter(&gzData)
+	gw.Write([]byte("hello"))
+	gw.Close()
+
+	bgzfData, _ := makeBGZF(t, [][]byte{[]byte("hello")})
+
+	header := func(magic string) []byte {
+		b := make([]byte, 512)
+		copy(b[257:], magic)
+		return b
+	}
+
+	for _, tt := range []struct {
+		data []byte
+		want string
+	}{
+		{tarData, FormatTar},
+		{header("ustar  \x00"), FormatTar},
+		{header("ustarX"), FormatUnknown},
+		{zipData.Bytes(), FormatZip},
+		{gzData.Bytes(), FormatGzip},
+		{bgzfData, FormatBGZF},
+		{[]byte("PAR1...."), FormatParquet},
+		{[]byte("hello"), FormatUnknown},
+		{nil, FormatUnknown},
+	} {
+		s := New("https://example.com/file")
+		s.Client = &RangeMockHTTPClient{data: tt.data}
+		s.Logger = &logger{t: t}
+		got, err := s.Sniff()
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestSniffTarCache(t *testing.T) {
+	data, _ := makeTar(t, []tarFile{{"a.txt", "hello"}, {"b.txt", "world"}})
+	m := &RangeMockHTTPClient{data: data}
+	s := New("https://example.com/file")
+	s.Client = m
+	s.Logger = &logger{t: t}
+	s.BlockSize = 100
+
+	// The tar magic number is at 257-262, so a shorter sniff misses it.
+	s.SniffLength = 262
+	got, err := s.Sniff()
+	assert.NoError(t, err)
+	assert.Equal(t, FormatUnknown, got)
+
+	s = New("https://example.com/file")
+	s.Client = m
+	s.Logger = &logger{t: t}
+	s.BlockSize = 100
+	m.ranges = nil
+	got, err = s.Sniff()
+	assert.NoError(t, err)
+	assert.Equal(t, FormatTar, got)
+	assert.Equal(t, []string{"bytes=0-511"}, m.ranges)
+
+	// Reading the first header is served from the cache.
+	buf := make([]byte, tarBlockSize)
+	_, err = s.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(m.ranges))
+}
+
+func TestSniffStreamThreshold(t *testing.T) {
+	data, _ := makeTar(t, []tarFile{{"a.txt", "hello"}})
+	m := &RangeMockHTTPClient{data: data}
+	s := New("https://example.com/file")
+	s.Client = m
+	s.Logger = &logger{t: t}
+	s.BlockSize = 100
+	s.StreamThreshold = 100
+
+	// The sniff goes through the cache, even though it is over the
+	// threshold, so the first header is still read from it.
+	got, err := s.Sniff()
+	assert.NoError(t, err)
+	assert.Equal(t, FormatTar, got)
+	_, err = s.ReadAt(make([]byte, tarBlockSize), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bytes=0-511"}, m.ranges)
+}
@@ -0,0 +1,82 @@
+package seekinghttp
+
+import (
+	"io"
+	"os"
+)
+
+// spooled returns the temporary file holding the whole file, if there is
+// one.
+func (s *SeekingHTTP) spooled() *os.File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spoolFile
+}
+
+// spool saves the whole file, which the server is sending in body, to a
+// temporary file for TempFileFallback, and returns it.
+func (s *SeekingHTTP) spool(body io.Reader) (*os.File, error) {
+	f, err := os.CreateTemp("", "seekinghttp-*")
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(f, s.limitBody(body))
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if other := s.spoolFile; other != nil {
+		// Another read got there first.
+		s.mu.Unlock()
+		f.Close()
+		os.Remove(f.Name())
+		return other, nil
+	}
+	s.spoolFile = f
+	s.mu.Unlock()
+
+	s.setSize(size)
+	if s.Logger != nil {
+		s.Logger.Infof("Server does not support ranges, saved %v bytes to %v", size, f.Name())
+	}
+	s.fallback(FallbackFullDownload, "server does not support ranges, saved %v bytes to a temporary file", size)
+	return f, nil
+}
+
+// readSuffixFile is ReadSuffix for a file on disk.
+func readSuffixFile(f *os.File, n int64) ([]byte, int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	start := fi.Size() - n
+	if start < 0 {
+		start = 0
+	}
+	buf := make([]byte, fi.Size()-start)
+	if _, err := f.ReadAt(buf, start); err != nil {
+		return nil, 0, err
+	}
+	return buf, start, nil
+}
+
+// Close removes the temporary file made by TempFileFallback, if any.
+// Reads after Close go to the server again.
+func (s *SeekingHTTP) Close() error {
+	s.mu.Lock()
+	f := s.spoolFile
+	s.spoolFile = nil
+	s.mu.Unlock()
+
+	if f == nil {
+		return nil
+	}
+	err := f.Close()
+	if rmErr := os.Remove(f.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
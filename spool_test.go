@@ -0,0 +1,76 @@
+package seekinghttp
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTempFileFallback(t *testing.T) {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, name := range []string{"a.txt", "dir/b.txt", "c.txt"} {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		io.WriteString(w, "contents of "+name)
+	}
+	assert.NoError(t, zw.Close())
+	data := b.Bytes()
+
+	methods := map[string]int{}
+	s := New("https://example.com/test.zip")
+	// This server ignores ranges and always sends the whole file.
+	s.Client = clientFunc(func(req *http.Request) (*http.Response, error) {
+		methods[req.Method]++
+		body := io.NopCloser(bytes.NewReader(data))
+		if req.Method == "HEAD" {
+			body = http.NoBody
+		}
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			ContentLength: int64(len(data)),
+			Body:          body,
+			Request:       req,
+		}, nil
+	})
+	s.Logger = &logger{t: t}
+	s.BlockSize = 16
+	s.TempFileFallback = true
+
+	size, err := s.Size()
+	assert.NoError(t, err)
+	z, err := zip.NewReader(s, size)
+	assert.NoError(t, err)
+	var names []string
+	for _, f := range z.File {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"a.txt", "dir/b.txt", "c.txt"}, names)
+
+	r, err := z.Open("dir/b.txt")
+	assert.NoError(t, err)
+	contents, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "contents of dir/b.txt", string(contents))
+
+	suffix, start, err := s.ReadSuffix(10)
+	assert.NoError(t, err)
+	assert.Equal(t, data[len(data)-10:], suffix)
+	assert.Equal(t, int64(len(data)-10), start)
+
+	// Everything after the first read came from the temporary file.
+	assert.Equal(t, map[string]int{"HEAD": 1, "GET": 1}, methods)
+
+	name := s.spooled().Name()
+	_, err = os.Stat(name)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Close())
+	_, err = os.Stat(name)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.NoError(t, s.Close())
+}
@@ -0,0 +1,127 @@
+package seekinghttp
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+const tarBlockSize = 512
+
+// errStopWalk stops walkTar without an error.
+var errStopWalk = errors.New("stop walk")
+
+// countingReader counts the bytes read through it. It deliberately does
+// not implement io.Seeker, so that tar.Reader reads the headers exactly.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(buf []byte) (int, error) {
+	n, err := c.r.Read(buf)
+	c.n += int64(n)
+	return n, err
+}
+
+// walkTar calls fn with the header of each entry in the tar file, in
+// order. The contents of each entry are skipped using the size in its
+// header, so they are never fetched. If fn wants the contents, it can read
+// them from tr, but then it must return errStopWalk, which ends the walk
+// without an error.
+func (s *SeekingHTTP) walkTar(fn func(h *tar.Header, tr *tar.Reader) error) error {
+	var off int64
+	for {
+		cr := &countingReader{r: io.NewSectionReader(s, off, math.MaxInt64-off)}
+		tr := tar.NewReader(cr)
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start := off + cr.n
+
+		if err := fn(h, tr); err != nil {
+			if err == errStopWalk {
+				return nil
+			}
+			return err
+		}
+
+		if isSparse(h) {
+			// The size in the header is not the size in the archive, so
+			// read through the contents to find the next header.
+			if _, err := io.Copy(io.Discard, tr); err != nil {
+				return err
+			}
+			off = roundUpTar(off + cr.n)
+			continue
+		}
+		off = start + roundUpTar(h.Size)
+	}
+}
+
+func roundUpTar(n int64) int64 {
+	return (n + tarBlockSize - 1) / tarBlockSize * tarBlockSize
+}
+
+func isSparse(h *tar.Header) bool {
+	if h.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for k := range h.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// ListTarDetailed returns the headers of the entries in a tar file, with
+// the full metadata that tar.Reader gives, such as mode, modification time
+// and owner. Long names from PAX extended headers or GNU long name entries
+// are resolved. Only the headers are fetched, not the contents of the
+// entries, so a small BlockSize saves the most when the files are large.
+func (s *SeekingHTTP) ListTarDetailed() ([]tar.Header, error) {
+	var headers []tar.Header
+	err := s.walkTar(func(h *tar.Header, tr *tar.Reader) error {
+		headers = append(headers, *h)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// ExtractTarEntry copies the contents of the named entry of a tar file to
+// w. The contents of the entries before it are skipped over rather than
+// read, so only their headers are fetched. Each fetch still reads ahead
+// by up to BlockSize bytes, so a small BlockSize saves the most when the
+// skipped files are large.
+func (s *SeekingHTTP) ExtractTarEntry(name string, w io.Writer) error {
+	found := false
+	err := s.walkTar(func(h *tar.Header, tr *tar.Reader) error {
+		if h.Name != name {
+			return nil
+		}
+		found = true
+		if _, err := io.Copy(w, tr); err != nil {
+			return err
+		}
+		return errStopWalk
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("tar entry %q: %w", name, os.ErrNotExist)
+	}
+	return nil
+}
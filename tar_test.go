@@ -0,0 +1,168 @@
+package seekinghttp
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tarFile struct {
+	name     string
+	contents string
+}
+
+// makeTar returns a tar file holding files, and the offset of each file's
+// contents within it.
+func makeTar(t *testing.T, files []tarFile) ([]byte, []int64) {
+	var b bytes.Buffer
+	w := tar.NewWriter(&b)
+	var offsets []int64
+	for _, f := range files {
+		err := w.WriteHeader(&tar.Header{
+			Name:     f.name,
+			Mode:     0644,
+			Size:     int64(len(f.contents)),
+			Typeflag: tar.TypeReg,
+		})
+		assert.NoError(t, err)
+		offsets = append(offsets, int64(b.Len()))
+		_, err = w.Write([]byte(f.contents))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+	return b.Bytes(), offsets
+}
+
+// fetched reports whether any of the requested ranges overlap [from, to).
+func fetched(ranges []string, from, to int64) bool {
+	for _, rng := range ranges {
+		a, b, _ := strings.Cut(strings.TrimPrefix(rng, "bytes="), "-")
+		start, _ := strconv.ParseInt(a, 10, 64)
+		end, _ := strconv.ParseInt(b, 10, 64)
+		if start < to && end >= from {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExtractTarEntry(t *testing.T) {
+	files := []tarFile{
+		{"big/a.bin", strings.Repeat("a", 64*1024)},
+		{"big/b.bin", strings.Repeat("b", 100*1024+1)},
+		{"wanted.txt", "hello, world\n"},
+		{"after.txt", "not needed"},
+	}
+	data, offsets := makeTar(t, files)
+
+	m := &RangeMockHTTPClient{data: data}
+	s := New("https://example.com/test.tar")
+	s.Client = m
+	s.Logger = &logger{t: t}
+	s.BlockSize = tarBlockSize
+
+	var out bytes.Buffer
+	err := s.ExtractTarEntry("wanted.txt", &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello, world\n", out.String())
+
+	for i, f := range files[:2] {
+		assert.False(t, fetched(m.ranges, offsets[i], offsets[i]+int64(len(f.contents))), "contents of %v were fetched", f.name)
+	}
+	// Three headers and the contents.
+	assert.Equal(t, 4, len(m.ranges))
+}
+
+func TestExtractTarEntryNotFound(t *testing.T) {
+	data, _ := makeTar(t, []tarFile{{"a.txt", "a"}, {"b.txt", "b"}})
+	s := New("https://example.com/test.tar")
+	s.Client = &RangeMockHTTPClient{data: data}
+	s.Logger = &logger{t: t}
+
+	err := s.ExtractTarEntry("c.txt", &bytes.Buffer{})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestListTarDetailed(t *testing.T) {
+	long := strings.Repeat("very-long-directory-name/", 8) + "file.txt"
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var b bytes.Buffer
+	w := tar.NewWriter(&b)
+	var offsets []int64
+	for _, h := range []*tar.Header{
+		{Name: long, Mode: 0600, Uid: 1000, Gid: 100, Uname: "alice", ModTime: mtime, Size: 5000, Format: tar.FormatPAX},
+		{Name: long + ".gnu", Mode: 0755, Uid: 1, Gid: 2, ModTime: mtime, Size: 5000, Format: tar.FormatGNU},
+		{Name: "short", Mode: 0644, ModTime: mtime, Size: 1},
+	} {
+		h.Typeflag = tar.TypeReg
+		assert.NoError(t, w.WriteHeader(h))
+		offsets = append(offsets, int64(b.Len()))
+		_, err := w.Write(bytes.Repeat([]byte("x"), int(h.Size)))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+
+	m := &RangeMockHTTPClient{data: b.Bytes()}
+	s := New("https://example.com/test.tar")
+	s.Client = m
+	s.Logger = &logger{t: t}
+	s.BlockSize = tarBlockSize
+
+	headers, err := s.ListTarDetailed()
+	assert.NoError(t, err)
+	if !assert.Equal(t, 3, len(headers)) {
+		return
+	}
+	assert.Equal(t, long, headers[0].Name)
+	assert.Equal(t, int64(0600), headers[0].Mode)
+	assert.Equal(t, 1000, headers[0].Uid)
+	assert.Equal(t, 100, headers[0].Gid)
+	assert.Equal(t, "alice", headers[0].Uname)
+	assert.True(t, mtime.Equal(headers[0].ModTime))
+	assert.Equal(t, long+".gnu", headers[1].Name)
+	assert.Equal(t, int64(0755), headers[1].Mode)
+	assert.Equal(t, "short", headers[2].Name)
+	assert.Equal(t, int64(1), headers[2].Size)
+
+	for i := range offsets[:2] {
+		assert.False(t, fetched(m.ranges, offsets[i]+tarBlockSize, offsets[i]+4500), "contents of entry %v were fetched", i)
+	}
+}
+
+func TestTarMaxRequestsPerOp(t *testing.T) {
+	data, _ := makeTar(t, []tarFile{
+		{"a.bin", strings.Repeat("a", 10000)},
+		{"b.bin", strings.Repeat("b", 10000)},
+		{"c.txt", "c"},
+	})
+	m := &RangeMockHTTPClient{data: data}
+	s := New("https://example.com/test.tar")
+	s.Client = m
+	s.Logger = &logger{t: t}
+	s.BlockSize = tarBlockSize
+	s.MaxRequestsPerOp = 5
+
+	// Each listing is an operation of its own, with its own allowance.
+	for i := 0; i < 2; i++ {
+		s.BeginOp()
+		headers, err := s.ListTarDetailed()
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(headers))
+	}
+	assert.Equal(t, 10, len(m.ranges))
+
+	// Without a BeginOp, the extraction counts against the same allowance.
+	var out bytes.Buffer
+	assert.ErrorIs(t, s.ExtractTarEntry("c.txt", &out), ErrTooManyRequests)
+
+	s.BeginOp()
+	out.Reset()
+	assert.NoError(t, s.ExtractTarEntry("c.txt", &out))
+	assert.Equal(t, "c", out.String())
+}
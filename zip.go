@@ -0,0 +1,152 @@
+package seekinghttp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	zipEOCDSignature      = 0x06054b50
+	zipEOCDLen            = 22
+	zip64LocatorSignature = 0x07064b50
+	zip64LocatorLen       = 20
+	zip64EOCDSignature    = 0x06064b50
+	zip64EOCDLen          = 56
+	zipMaxCommentLen      = 0xffff
+)
+
+// MaxZipTrailingBytes is how many bytes of trailing data, such as a
+// signature appended by a signing tool, ReadZipEOCD allows after the end of
+// central directory record of a zip file. Set it to zero to only accept
+// zip files that end with the record and its comment.
+var MaxZipTrailingBytes = 64 * 1024
+
+// ZipEOCD holds the archive-level information from the end of
+// central directory record of a zip file.
+type ZipEOCD struct {
+	// Offset is where the end of central directory record starts.
+	Offset int64
+
+	// Entries is the total number of files in the archive.
+	Entries uint64
+
+	// DirectoryOffset and DirectorySize locate the central directory.
+	DirectoryOffset int64
+	DirectorySize   int64
+
+	// Comment is the archive comment.
+	Comment string
+
+	// Zip64 is true if the values came from a zip64 end of
+	// central directory record.
+	Zip64 bool
+}
+
+// ReadZipEOCD reads the end of central directory record of a zip file
+// with a suffix range request, without fetching the central directory
+// itself. This is a cheap way to get the archive comment or the number
+// of entries. If the record is not at the very end of the file, because of
+// up to MaxZipTrailingBytes of trailing data, a second, longer suffix may
+// be needed to find it.
+func (s *SeekingHTTP) ReadZipEOCD() (*ZipEOCD, error) {
+	window := int64(zipEOCDLen + zipMaxCommentLen + zip64LocatorLen)
+	buf, start, err := s.ReadSuffix(window)
+	if err != nil {
+		return nil, err
+	}
+
+	i := findZipEOCD(buf, MaxZipTrailingBytes)
+	if i < 0 && MaxZipTrailingBytes > 0 && start > 0 {
+		// The trailing data may have pushed the record out of the window.
+		buf, start, err = s.ReadSuffix(window + int64(MaxZipTrailingBytes))
+		if err != nil {
+			return nil, err
+		}
+		i = findZipEOCD(buf, MaxZipTrailingBytes)
+	}
+	if i < 0 {
+		return nil, errors.New("zip: end of central directory record not found")
+	}
+
+	b := buf[i:]
+	commentLen := int(binary.LittleEndian.Uint16(b[20:]))
+	e := &ZipEOCD{
+		Offset:          start + int64(i),
+		Entries:         uint64(binary.LittleEndian.Uint16(b[10:])),
+		DirectorySize:   int64(binary.LittleEndian.Uint32(b[12:])),
+		DirectoryOffset: int64(binary.LittleEndian.Uint32(b[16:])),
+		Comment:         string(b[zipEOCDLen : zipEOCDLen+commentLen]),
+	}
+
+	// A zip64 archive has a locator just before the EOCD record, which
+	// points at the zip64 version of the record.
+	if i < zip64LocatorLen {
+		return e, nil
+	}
+	loc := buf[i-zip64LocatorLen : i]
+	if binary.LittleEndian.Uint32(loc) != zip64LocatorSignature {
+		return e, nil
+	}
+	off := int64(binary.LittleEndian.Uint64(loc[8:]))
+
+	var rec []byte
+	if off >= start && off+zip64EOCDLen <= start+int64(i) {
+		rec = buf[off-start : off-start+zip64EOCDLen]
+	} else {
+		rec = make([]byte, zip64EOCDLen)
+		n, err := s.ReadAt(rec, off)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if n < len(rec) {
+			return nil, io.ErrUnexpectedEOF
+		}
+	}
+	if binary.LittleEndian.Uint32(rec) != zip64EOCDSignature {
+		return nil, errors.New("zip: invalid zip64 end of central directory record")
+	}
+
+	e.Zip64 = true
+	e.Entries = binary.LittleEndian.Uint64(rec[32:])
+	e.DirectorySize = int64(binary.LittleEndian.Uint64(rec[40:]))
+	e.DirectoryOffset = int64(binary.LittleEndian.Uint64(rec[48:]))
+	return e, nil
+}
+
+// findZipEOCD returns the index of the end of central directory record
+// in buf, which must hold the tail of the file, or -1 if there is none.
+// The search goes backwards and prefers a record whose comment runs
+// exactly to the end of the file. Failing that, it takes the last
+// plausible record followed by up to maxTrailing bytes of other data.
+func findZipEOCD(buf []byte, maxTrailing int) int {
+	for i := len(buf) - zipEOCDLen; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(buf[i:]) != zipEOCDSignature {
+			continue
+		}
+		commentLen := int(binary.LittleEndian.Uint16(buf[i+20:]))
+		if i+zipEOCDLen+commentLen == len(buf) {
+			return i
+		}
+	}
+
+	for i := len(buf) - zipEOCDLen; i >= 0 && len(buf)-i-zipEOCDLen <= maxTrailing; i-- {
+		if binary.LittleEndian.Uint32(buf[i:]) != zipEOCDSignature {
+			continue
+		}
+		b := buf[i:]
+		end := i + zipEOCDLen + int(binary.LittleEndian.Uint16(b[20:]))
+		if end > len(buf) || len(buf)-end > maxTrailing {
+			continue
+		}
+		// Without the comment reaching the end to go by, check that the
+		// disk numbers and entry counts agree, as they do in any archive
+		// that is not split across disks.
+		le := binary.LittleEndian
+		if le.Uint16(b[4:]) != le.Uint16(b[6:]) || le.Uint16(b[8:]) != le.Uint16(b[10:]) {
+			continue
+		}
+		return i
+	}
+	return -1
+}
@@ -0,0 +1,147 @@
+package seekinghttp
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadZipEOCD(t *testing.T) {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for _, name := range []string{"a.txt", "b.txt", "c/d.txt"} {
+		f, err := w.Create(name)
+		assert.NoError(t, err)
+		_, err = f.Write([]byte("contents of " + name))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.SetComment("archive comment"))
+	assert.NoError(t, w.Close())
+	data := b.Bytes()
+
+	s := New("https://example.com/test.zip")
+	m := &RangeMockHTTPClient{data: data}
+	s.Client = m
+	s.Logger = &logger{t: t}
+
+	e, err := s.ReadZipEOCD()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), e.Entries)
+	assert.Equal(t, "archive comment", e.Comment)
+	assert.False(t, e.Zip64)
+	assert.Equal(t, "PK\x01\x02", string(data[e.DirectoryOffset:e.DirectoryOffset+4]))
+	assert.Equal(t, e.Offset, e.DirectoryOffset+e.DirectorySize)
+	assert.Equal(t, "PK\x05\x06", string(data[e.Offset:e.Offset+4]))
+
+	// Only the tail of the file was fetched.
+	assert.Equal(t, []string{"bytes=-65577"}, m.ranges)
+}
+
+func TestReadZipEOCDZip64(t *testing.T) {
+	const (
+		entries = 70000
+		cdSize  = 0x100000010
+		cdOff   = 0x123456789
+	)
+	le := binary.LittleEndian
+
+	var b bytes.Buffer
+	b.WriteString("pretend this is a large archive")
+
+	rec := make([]byte, zip64EOCDLen)
+	le.PutUint32(rec, zip64EOCDSignature)
+	le.PutUint64(rec[4:], zip64EOCDLen-12)
+	le.PutUint64(rec[24:], entries)
+	le.PutUint64(rec[32:], entries)
+	le.PutUint64(rec[40:], cdSize)
+	le.PutUint64(rec[48:], cdOff)
+	recOff := b.Len()
+	b.Write(rec)
+
+	loc := make([]byte, zip64LocatorLen)
+	le.PutUint32(loc, zip64LocatorSignature)
+	le.PutUint64(loc[8:], uint64(recOff))
+	le.PutUint32(loc[16:], 1)
+	b.Write(loc)
+
+	eocd := make([]byte, zipEOCDLen)
+	le.PutUint32(eocd, zipEOCDSignature)
+	le.PutUint16(eocd[8:], 0xffff)
+	le.PutUint16(eocd[10:], 0xffff)
+	le.PutUint32(eocd[12:], 0xffffffff)
+	le.PutUint32(eocd[16:], 0xffffffff)
+	le.PutUint16(eocd[20:], 2)
+	eocdOff := b.Len()
+	b.Write(eocd)
+	b.WriteString("hi")
+
+	s := New("https://example.com/big.zip")
+	m := &RangeMockHTTPClient{data: b.Bytes()}
+	s.Client = m
+	s.Logger = &logger{t: t}
+
+	e, err := s.ReadZipEOCD()
+	assert.NoError(t, err)
+	assert.True(t, e.Zip64)
+	assert.Equal(t, uint64(entries), e.Entries)
+	assert.Equal(t, int64(cdSize), e.DirectorySize)
+	assert.Equal(t, int64(cdOff), e.DirectoryOffset)
+	assert.Equal(t, int64(eocdOff), e.Offset)
+	assert.Equal(t, "hi", e.Comment)
+	assert.Equal(t, 1, len(m.ranges))
+}
+
+func TestReadZipEOCDTrailingData(t *testing.T) {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		_, err := w.Create(name)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.SetComment("signed"))
+	assert.NoError(t, w.Close())
+	eocdOff := int64(b.Len() - zipEOCDLen - len("signed"))
+
+	// A fake record in the trailing data, with entry counts that do not
+	// agree, is not mistaken for the real one.
+	fake := make([]byte, zipEOCDLen)
+	binary.LittleEndian.PutUint32(fake, zipEOCDSignature)
+	binary.LittleEndian.PutUint16(fake[8:], 1)
+	binary.LittleEndian.PutUint16(fake[10:], 7)
+	b.WriteString("-----BEGIN SIGNATURE-----")
+	b.Write(fake)
+	b.WriteString("-----END SIGNATURE-----")
+	data := b.Bytes()
+
+	s := New("https://example.com/test.zip")
+	m := &RangeMockHTTPClient{data: data}
+	s.Client = m
+	s.Logger = &logger{t: t}
+
+	e, err := s.ReadZipEOCD()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), e.Entries)
+	assert.Equal(t, "signed", e.Comment)
+	assert.Equal(t, eocdOff, e.Offset)
+	assert.Equal(t, 1, len(m.ranges))
+
+	// Lots of trailing data needs a second, longer suffix.
+	defer func(n int) { MaxZipTrailingBytes = n }(MaxZipTrailingBytes)
+	MaxZipTrailingBytes = 100000
+	padded := append(append([]byte{}, data...), make([]byte, 70000)...)
+	m = &RangeMockHTTPClient{data: padded}
+	s.Client = m
+	e, err = s.ReadZipEOCD()
+	assert.NoError(t, err)
+	assert.Equal(t, eocdOff, e.Offset)
+	assert.Equal(t, []string{"bytes=-65577", "bytes=-165577"}, m.ranges)
+
+	// Unless trailing data is not allowed.
+	MaxZipTrailingBytes = 0
+	s.Client = &RangeMockHTTPClient{data: data}
+	_, err = s.ReadZipEOCD()
+	assert.Error(t, err)
+}